@@ -5,37 +5,103 @@ package client
 
 import (
 	"context"
+	"time"
 
 	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/crypto/secp256k1"
 	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/json"
 	"github.com/ava-labs/avalanchego/utils/rpc"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
 
 	"github.com/ava-labs/timestampvm/timestampvm"
 )
 
 // Client defines timestampvm client operations.
 type Client interface {
-	// ProposeBlock submits data for a block
-	ProposeBlock(ctx context.Context, data [timestampvm.DataLen]byte) (bool, error)
+	// ProposeBlock submits data for a block, and returns the ID of the
+	// proposal
+	ProposeBlock(ctx context.Context, data []byte) (ids.ID, bool, error)
+
+	// ProposeSignedBlock submits data for a block, signed by key with nonce
+	// for the chain chainID, and returns the ID of the proposal
+	ProposeSignedBlock(ctx context.Context, data []byte, key *secp256k1.PrivateKey, chainID ids.ID, nonce uint64) (ids.ID, bool, error)
+
+	// ProposeBatch submits each piece of data for a block in a single call,
+	// and returns the result of each proposal
+	ProposeBatch(ctx context.Context, data [][]byte) ([]timestampvm.ProposeBatchResult, error)
+
+	// GetProposalStatus fetches what became of a proposal on the node
+	GetProposalStatus(ctx context.Context, proposalID ids.ID) (*timestampvm.GetProposalStatusReply, error)
 
 	// GetBlock fetches the contents of a block
-	GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [timestampvm.DataLen]byte, uint64, ids.ID, ids.ID, error)
+	GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [][]byte, uint64, ids.ID, ids.ID, error)
+
+	// GetBlockTime fetches the timestamp of a block, at millisecond precision
+	// from the millisecondTimestamp upgrade on
+	GetBlockTime(ctx context.Context, blockID *ids.ID) (time.Time, error)
+
+	// GetBlockByHeight fetches the contents of the block accepted at a height
+	GetBlockByHeight(ctx context.Context, height uint64) (uint64, [][]byte, uint64, ids.ID, ids.ID, error)
+
+	// GetBlockByData fetches the contents of the earliest accepted block
+	// holding data
+	GetBlockByData(ctx context.Context, data []byte) (uint64, [][]byte, uint64, ids.ID, ids.ID, error)
+
+	// GetBlocksByTimeRange fetches a page of the accepted blocks whose
+	// timestamps are in [start, end), from cursor if it isn't nil, along with
+	// the cursor of the next page if there is one
+	GetBlocksByTimeRange(ctx context.Context, start, end time.Time, limit uint32, cursor *uint64) ([]timestampvm.GetBlockReply, *uint64, error)
+
+	// GetBlocks fetches a page of the accepted blocks in the height range of
+	// args, from args.Cursor if it isn't nil, along with the cursor of the
+	// next page if there is one
+	GetBlocks(ctx context.Context, args timestampvm.GetBlocksArgs) ([]timestampvm.GetBlockReply, *uint64, error)
+
+	// IterateBlocks returns an iterator over the pages of the accepted blocks
+	// in the height range of args
+	IterateBlocks(args timestampvm.GetBlocksArgs) *BlockIterator
+
+	// GetBlockAtTime fetches the contents of the latest accepted block whose
+	// timestamp is at or before t
+	GetBlockAtTime(ctx context.Context, t time.Time) (uint64, [][]byte, uint64, ids.ID, ids.ID, error)
+
+	// GetInclusionProof fetches the proof that data accepted at a height is
+	// committed to by the root of the block at rootHeight, or of the last
+	// accepted block if rootHeight is nil
+	GetInclusionProof(ctx context.Context, height uint64, data []byte, rootHeight *uint64) (ids.ID, uint64, ids.ID, *timestampvm.InclusionProof, error)
+
+	// GetReceipt fetches the receipt of data accepted at a height, leading to
+	// the block at checkpointHeight, or to the last accepted block if
+	// checkpointHeight is nil. The receipt can be checked offline with the
+	// verifier package.
+	GetReceipt(ctx context.Context, height uint64, data []byte, checkpointHeight *uint64) ([]byte, ids.ID, uint64, error)
+
+	// GetWarpSignature fetches the unsigned Warp message stating that data
+	// was accepted at a height, and the node's signature over it
+	GetWarpSignature(ctx context.Context, height uint64, data []byte) (*warp.UnsignedMessage, []byte, error)
+
+	// Subscribe opens a subscription to the blocks accepted from now on, or
+	// from fromHeight on if it isn't nil, and to the rejected blocks if
+	// rejected is true
+	Subscribe(ctx context.Context, fromHeight *uint64, rejected bool) (*Subscription, error)
 }
 
 // New creates a new client object.
 func New(uri string) Client {
 	req := rpc.NewEndpointRequester(uri)
-	return &client{req: req}
+	return &client{uri: uri, req: req}
 }
 
 type client struct {
+	uri string
 	req rpc.EndpointRequester
 }
 
-func (cli *client) ProposeBlock(ctx context.Context, data [timestampvm.DataLen]byte) (bool, error) {
-	bytes, err := formatting.Encode(formatting.Hex, data[:])
+func (cli *client) ProposeBlock(ctx context.Context, data []byte) (ids.ID, bool, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
 	if err != nil {
-		return false, err
+		return ids.Empty, false, err
 	}
 
 	resp := new(timestampvm.ProposeBlockReply)
@@ -45,12 +111,77 @@ func (cli *client) ProposeBlock(ctx context.Context, data [timestampvm.DataLen]b
 		resp,
 	)
 	if err != nil {
-		return false, err
+		return ids.Empty, false, err
+	}
+	return resp.ProposalID, resp.Success, nil
+}
+
+func (cli *client) ProposeSignedBlock(ctx context.Context, data []byte, key *secp256k1.PrivateKey, chainID ids.ID, nonce uint64) (ids.ID, bool, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
+	if err != nil {
+		return ids.Empty, false, err
+	}
+	sub, err := timestampvm.SignSubmission(key, chainID, nonce, data)
+	if err != nil {
+		return ids.Empty, false, err
+	}
+	sig, err := formatting.Encode(formatting.Hex, sub.Sig[:])
+	if err != nil {
+		return ids.Empty, false, err
+	}
+
+	resp := new(timestampvm.ProposeBlockReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.proposeBlock",
+		&timestampvm.ProposeBlockArgs{
+			Data:      bytes,
+			Signature: sig,
+			Nonce:     json.Uint64(nonce),
+		},
+		resp,
+	)
+	if err != nil {
+		return ids.Empty, false, err
+	}
+	return resp.ProposalID, resp.Success, nil
+}
+
+func (cli *client) ProposeBatch(ctx context.Context, data [][]byte) ([]timestampvm.ProposeBatchResult, error) {
+	var err error
+	encoded := make([]string, len(data))
+	for i, piece := range data {
+		encoded[i], err = formatting.Encode(formatting.Hex, piece)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	resp := new(timestampvm.ProposeBatchReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.proposeBatch",
+		&timestampvm.ProposeBatchArgs{Data: encoded},
+		resp,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Results, nil
+}
+
+func (cli *client) GetProposalStatus(ctx context.Context, proposalID ids.ID) (*timestampvm.GetProposalStatusReply, error) {
+	resp := new(timestampvm.GetProposalStatusReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getProposalStatus",
+		&timestampvm.GetProposalStatusArgs{ProposalID: proposalID},
+		resp,
+	)
+	if err != nil {
+		return nil, err
 	}
-	return resp.Success, nil
+	return resp, nil
 }
 
-func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [timestampvm.DataLen]byte, uint64, ids.ID, ids.ID, error) {
+func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
 	resp := new(timestampvm.GetBlockReply)
 	err := cli.req.SendRequest(ctx,
 		"timestampvm.getBlock",
@@ -58,11 +189,216 @@ func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [time
 		resp,
 	)
 	if err != nil {
-		return 0, [timestampvm.DataLen]byte{}, 0, ids.Empty, ids.Empty, err
+		return 0, nil, 0, ids.Empty, ids.Empty, err
 	}
-	bytes, err := formatting.Decode(formatting.Hex, resp.Data)
+	return parseBlockReply(resp)
+}
+
+func (cli *client) GetBlockTime(ctx context.Context, blockID *ids.ID) (time.Time, error) {
+	resp := new(timestampvm.GetBlockReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getBlock",
+		&timestampvm.GetBlockArgs{ID: blockID},
+		resp,
+	)
 	if err != nil {
-		return 0, [timestampvm.DataLen]byte{}, 0, ids.Empty, ids.Empty, err
+		return time.Time{}, err
+	}
+	return time.UnixMilli(int64(resp.TimestampMs)), nil
+}
+
+func (cli *client) GetBlockByHeight(ctx context.Context, height uint64) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
+	resp := new(timestampvm.GetBlockReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getBlockByHeight",
+		&timestampvm.GetBlockByHeightArgs{Height: json.Uint64(height)},
+		resp,
+	)
+	if err != nil {
+		return 0, nil, 0, ids.Empty, ids.Empty, err
+	}
+	return parseBlockReply(resp)
+}
+
+func (cli *client) GetBlockByData(ctx context.Context, data []byte) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
+	if err != nil {
+		return 0, nil, 0, ids.Empty, ids.Empty, err
+	}
+
+	resp := new(timestampvm.GetBlockReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.getBlockByData",
+		&timestampvm.GetBlockByDataArgs{Data: bytes},
+		resp,
+	)
+	if err != nil {
+		return 0, nil, 0, ids.Empty, ids.Empty, err
+	}
+	return parseBlockReply(resp)
+}
+
+func (cli *client) GetBlocksByTimeRange(ctx context.Context, start, end time.Time, limit uint32, cursor *uint64) ([]timestampvm.GetBlockReply, *uint64, error) {
+	args := &timestampvm.GetBlocksByTimeRangeArgs{
+		Start: json.Uint64(start.Unix()),
+		End:   json.Uint64(end.Unix()),
+		Limit: json.Uint32(limit),
+	}
+	if cursor != nil {
+		c := json.Uint64(*cursor)
+		args.Cursor = &c
+	}
+	resp := new(timestampvm.GetBlocksByTimeRangeReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getBlocksByTimeRange",
+		args,
+		resp,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.NextCursor == nil {
+		return resp.Blocks, nil, nil
+	}
+	nextCursor := uint64(*resp.NextCursor)
+	return resp.Blocks, &nextCursor, nil
+}
+
+func (cli *client) GetBlocks(ctx context.Context, args timestampvm.GetBlocksArgs) ([]timestampvm.GetBlockReply, *uint64, error) {
+	resp := new(timestampvm.GetBlocksReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getBlocks",
+		&args,
+		resp,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.NextCursor == nil {
+		return resp.Blocks, nil, nil
+	}
+	nextCursor := uint64(*resp.NextCursor)
+	return resp.Blocks, &nextCursor, nil
+}
+
+func (cli *client) IterateBlocks(args timestampvm.GetBlocksArgs) *BlockIterator {
+	return &BlockIterator{
+		cli:  cli,
+		args: args,
+	}
+}
+
+func (cli *client) GetBlockAtTime(ctx context.Context, t time.Time) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
+	resp := new(timestampvm.GetBlockReply)
+	err := cli.req.SendRequest(ctx,
+		"timestampvm.getBlockAtTime",
+		&timestampvm.GetBlockAtTimeArgs{Time: json.Uint64(t.Unix())},
+		resp,
+	)
+	if err != nil {
+		return 0, nil, 0, ids.Empty, ids.Empty, err
+	}
+	return parseBlockReply(resp)
+}
+
+func (cli *client) GetInclusionProof(ctx context.Context, height uint64, data []byte, rootHeight *uint64) (ids.ID, uint64, ids.ID, *timestampvm.InclusionProof, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
+	if err != nil {
+		return ids.Empty, 0, ids.Empty, nil, err
+	}
+
+	args := &timestampvm.GetInclusionProofArgs{
+		Height: json.Uint64(height),
+		Data:   bytes,
+	}
+	if rootHeight != nil {
+		h := json.Uint64(*rootHeight)
+		args.RootHeight = &h
+	}
+	resp := new(timestampvm.GetInclusionProofReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.getInclusionProof",
+		args,
+		resp,
+	)
+	if err != nil {
+		return ids.Empty, 0, ids.Empty, nil, err
+	}
+	return resp.BlockID, uint64(resp.Height), resp.Root, &resp.Proof, nil
+}
+
+func (cli *client) GetReceipt(ctx context.Context, height uint64, data []byte, checkpointHeight *uint64) ([]byte, ids.ID, uint64, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
+	if err != nil {
+		return nil, ids.Empty, 0, err
+	}
+
+	args := &timestampvm.GetReceiptArgs{
+		Height: json.Uint64(height),
+		Data:   bytes,
+	}
+	if checkpointHeight != nil {
+		h := json.Uint64(*checkpointHeight)
+		args.CheckpointHeight = &h
+	}
+	resp := new(timestampvm.GetReceiptReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.getReceipt",
+		args,
+		resp,
+	)
+	if err != nil {
+		return nil, ids.Empty, 0, err
+	}
+	receipt, err := formatting.Decode(formatting.Hex, resp.Receipt)
+	if err != nil {
+		return nil, ids.Empty, 0, err
+	}
+	return receipt, resp.CheckpointID, uint64(resp.CheckpointHeight), nil
+}
+
+func (cli *client) GetWarpSignature(ctx context.Context, height uint64, data []byte) (*warp.UnsignedMessage, []byte, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	resp := new(timestampvm.GetWarpSignatureReply)
+	err = cli.req.SendRequest(ctx,
+		"timestampvm.getWarpSignature",
+		&timestampvm.GetWarpSignatureArgs{
+			Height: json.Uint64(height),
+			Data:   bytes,
+		},
+		resp,
+	)
+	if err != nil {
+		return nil, nil, err
+	}
+	msgBytes, err := formatting.Decode(formatting.Hex, resp.Message)
+	if err != nil {
+		return nil, nil, err
+	}
+	msg, err := warp.ParseUnsignedMessage(msgBytes)
+	if err != nil {
+		return nil, nil, err
+	}
+	sig, err := formatting.Decode(formatting.Hex, resp.Signature)
+	if err != nil {
+		return nil, nil, err
+	}
+	return msg, sig, nil
+}
+
+// parseBlockReply decodes the contents of the block in [resp]
+func parseBlockReply(resp *timestampvm.GetBlockReply) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
+	var err error
+	entries := make([][]byte, len(resp.Data))
+	for i, data := range resp.Data {
+		entries[i], err = formatting.Decode(formatting.Hex, data)
+		if err != nil {
+			return 0, nil, 0, ids.Empty, ids.Empty, err
+		}
 	}
-	return uint64(resp.Timestamp), timestampvm.BytesToData(bytes), uint64(resp.Height), resp.ID, resp.ParentID, nil
+	return uint64(resp.Timestamp), entries, uint64(resp.Height), resp.ID, resp.ParentID, nil
 }
@@ -0,0 +1,66 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"context"
+
+	"github.com/ava-labs/avalanchego/utils/json"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+// BlockIterator fetches the pages of a block listing one at a time:
+//
+//	it := cli.IterateBlocks(args)
+//	for it.Next(ctx) {
+//		for _, blk := range it.Blocks() {
+//			...
+//		}
+//	}
+//	if err := it.Err(); err != nil {
+//		...
+//	}
+type BlockIterator struct {
+	cli  Client
+	args timestampvm.GetBlocksArgs
+
+	blocks []timestampvm.GetBlockReply
+	done   bool
+	err    error
+}
+
+// Next fetches the next page of blocks. Returns false once every page was
+// fetched, or if fetching a page failed.
+func (it *BlockIterator) Next(ctx context.Context) bool {
+	if it.done {
+		return false
+	}
+	blocks, nextCursor, err := it.cli.GetBlocks(ctx, it.args)
+	if err != nil {
+		it.blocks = nil
+		it.err = err
+		it.done = true
+		return false
+	}
+
+	it.blocks = blocks
+	if nextCursor == nil {
+		it.done = true
+	} else {
+		cursor := json.Uint64(*nextCursor)
+		it.args.Cursor = &cursor
+	}
+	return len(blocks) > 0
+}
+
+// Blocks returns the page of blocks fetched by the last call to Next
+func (it *BlockIterator) Blocks() []timestampvm.GetBlockReply {
+	return it.blocks
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *BlockIterator) Err() error {
+	return it.err
+}
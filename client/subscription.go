@@ -0,0 +1,120 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+// Subscription receives the block events pushed by a node over a WebSocket:
+//
+//	sub, err := cli.Subscribe(ctx, nil, false)
+//	...
+//	defer sub.Close()
+//	for event := range sub.Events() {
+//		...
+//	}
+//	if err := sub.Err(); err != nil {
+//		...
+//	}
+//
+// A subscriber that falls too far behind is dropped by the node. It can
+// resume from the height after the last accepted block it received.
+type Subscription struct {
+	conn   *websocket.Conn
+	events chan timestampvm.BlockEvent
+
+	closeOnce sync.Once
+	closed    chan struct{}
+	err       error
+}
+
+func (cli *client) Subscribe(ctx context.Context, fromHeight *uint64, rejected bool) (*Subscription, error) {
+	u, err := url.Parse(strings.TrimSuffix(cli.uri, "/") + timestampvm.EventsEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	switch u.Scheme {
+	case "http":
+		u.Scheme = "ws"
+	case "https":
+		u.Scheme = "wss"
+	}
+	query := u.Query()
+	if fromHeight != nil {
+		query.Set("fromHeight", strconv.FormatUint(*fromHeight, 10))
+	}
+	if rejected {
+		query.Set("rejected", "true")
+	}
+	u.RawQuery = query.Encode()
+
+	conn, resp, err := websocket.DefaultDialer.DialContext(ctx, u.String(), nil)
+	if err != nil {
+		if resp != nil {
+			return nil, fmt.Errorf("couldn't subscribe to %s: %w (status %s)", u, err, resp.Status)
+		}
+		return nil, fmt.Errorf("couldn't subscribe to %s: %w", u, err)
+	}
+	sub := &Subscription{
+		conn:   conn,
+		events: make(chan timestampvm.BlockEvent),
+		closed: make(chan struct{}),
+	}
+	go sub.read()
+	return sub, nil
+}
+
+// read passes the events pushed by the node to [Events] until the
+// subscription ends
+func (s *Subscription) read() {
+	defer close(s.events)
+	for {
+		var event timestampvm.BlockEvent
+		if err := s.conn.ReadJSON(&event); err != nil {
+			select {
+			case <-s.closed:
+			default:
+				s.err = err
+			}
+			return
+		}
+		select {
+		case s.events <- event:
+		case <-s.closed:
+			return
+		}
+	}
+}
+
+// Events returns the channel receiving the block events, in the order they
+// were decided. It's closed when the subscription ends.
+func (s *Subscription) Events() <-chan timestampvm.BlockEvent {
+	return s.events
+}
+
+// Err returns why the subscription ended, or nil if it was closed by
+// [Close]. Only valid once [Events] is closed.
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() error {
+	var err error
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		err = s.conn.Close()
+	})
+	return err
+}
@@ -0,0 +1,136 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package crosschain lets the other VMs of a node ask a timestampvm chain
+// whether data was accepted, and when, over cross chain app requests instead
+// of its HTTP API.
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+var errRequestFailed = errors.New("timestamp request failed")
+
+// result is the outcome of a pending request
+type result struct {
+	response *timestampvm.TimestampResponse
+	err      error
+}
+
+// Client sends timestamp requests to a timestampvm chain on behalf of another
+// VM of the node, and matches the responses to them.
+// The VM passes the cross chain responses and failures it receives to
+// [HandleResponse] and [HandleRequestFailed]. The client picks the IDs of the
+// requests it sends, so the VM must not send other cross chain requests to
+// the timestampvm chain.
+// Client is safe for concurrent use.
+type Client struct {
+	appSender common.AppSender
+	// ID of the timestampvm chain
+	chainID ids.ID
+
+	lock sync.Mutex
+	// ID of the next request
+	requestID uint32
+	// request ID --> channel receiving the outcome of the request
+	pending map[uint32]chan result
+}
+
+// NewClient returns a client sending requests to the timestampvm chain
+// [chainID] with [appSender], the sender of the requesting VM
+func NewClient(appSender common.AppSender, chainID ids.ID) *Client {
+	return &Client{
+		appSender: appSender,
+		chainID:   chainID,
+		pending:   make(map[uint32]chan result),
+	}
+}
+
+// GetTimestamp asks the timestampvm chain whether [data] was accepted, and at
+// which height and time. Waits for the response until [ctx] is done.
+// The caller must not hold a lock the VM takes to handle cross chain
+// responses.
+func (c *Client) GetTimestamp(ctx context.Context, data []byte) (*timestampvm.TimestampResponse, error) {
+	requestBytes, err := timestampvm.BuildCrossChainRequest(&timestampvm.TimestampRequest{Data: data})
+	if err != nil {
+		return nil, err
+	}
+
+	c.lock.Lock()
+	requestID := c.requestID
+	c.requestID++
+	resultChan := make(chan result, 1)
+	c.pending[requestID] = resultChan
+	c.lock.Unlock()
+
+	if err := c.appSender.SendCrossChainAppRequest(ctx, c.chainID, requestID, requestBytes); err != nil {
+		c.remove(requestID)
+		return nil, err
+	}
+
+	select {
+	case res := <-resultChan:
+		return res.response, res.err
+	case <-ctx.Done():
+		c.remove(requestID)
+		return nil, ctx.Err()
+	}
+}
+
+// HandleResponse passes [responseBytes], the response of [chainID] to the
+// request [requestID], to the request waiting for it.
+// Returns false if the response isn't for a pending request of this client.
+func (c *Client) HandleResponse(chainID ids.ID, requestID uint32, responseBytes []byte) bool {
+	resultChan, ok := c.take(chainID, requestID)
+	if !ok {
+		return false
+	}
+	response := &timestampvm.TimestampResponse{}
+	if _, err := timestampvm.Codec.Unmarshal(responseBytes, response); err != nil {
+		resultChan <- result{err: err}
+		return true
+	}
+	resultChan <- result{response: response}
+	return true
+}
+
+// HandleRequestFailed fails the request [requestID] to [chainID].
+// Returns false if it isn't a pending request of this client.
+func (c *Client) HandleRequestFailed(chainID ids.ID, requestID uint32) bool {
+	resultChan, ok := c.take(chainID, requestID)
+	if !ok {
+		return false
+	}
+	resultChan <- result{err: errRequestFailed}
+	return true
+}
+
+// take removes the pending request [requestID] to [chainID] and returns the
+// channel receiving its outcome
+func (c *Client) take(chainID ids.ID, requestID uint32) (chan result, bool) {
+	if chainID != c.chainID {
+		return nil, false
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	resultChan, ok := c.pending[requestID]
+	delete(c.pending, requestID)
+	return resultChan, ok
+}
+
+// remove forgets the pending request [requestID]
+func (c *Client) remove(requestID uint32) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	delete(c.pending, requestID)
+}
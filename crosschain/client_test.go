@@ -0,0 +1,125 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package crosschain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/manager"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+func TestGetTimestamp(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	// Route the requests of the client to the VM, and the responses of the VM
+	// back to the client, as the node does. Routing errors are reported on
+	// [routingErrs], as they happen outside of the test goroutine.
+	var (
+		vm           = &timestampvm.VM{}
+		client       *Client
+		chainID      = ids.GenerateTestID()
+		otherChainID = ids.GenerateTestID()
+		routingErrs  = make(chan error, 1)
+	)
+	reportRoutingErr := func(err error) {
+		select {
+		case routingErrs <- err:
+		default:
+		}
+	}
+	vmSender := &common.SenderTest{
+		SendCrossChainAppResponseF: func(_ context.Context, toChainID ids.ID, requestID uint32, responseBytes []byte) {
+			if toChainID != otherChainID {
+				reportRoutingErr(fmt.Errorf("response sent to chain %s", toChainID))
+				return
+			}
+			if !client.HandleResponse(chainID, requestID, responseBytes) {
+				reportRoutingErr(errors.New("response wasn't handled"))
+			}
+		},
+	}
+	clientSender := &common.SenderTest{
+		SendCrossChainAppRequestF: func(ctx context.Context, toChainID ids.ID, requestID uint32, requestBytes []byte) {
+			if toChainID != chainID {
+				reportRoutingErr(fmt.Errorf("request sent to chain %s", toChainID))
+				return
+			}
+			go func() {
+				if err := vm.CrossChainAppRequest(ctx, otherChainID, requestID, time.Time{}, requestBytes); err != nil {
+					reportRoutingErr(err)
+				}
+			}()
+		},
+	}
+	client = NewClient(clientSender, chainID)
+	// getTimestamp gets the timestamp of [data] through the client, and fails
+	// the test if routing the request or its response failed
+	getTimestamp := func(data []byte) (*timestampvm.TimestampResponse, error) {
+		ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+		defer cancel()
+		response, err := client.GetTimestamp(ctx, data)
+		select {
+		case routingErr := <-routingErrs:
+			require.NoError(routingErr)
+		default:
+		}
+		return response, err
+	}
+
+	snowCtx := snow.DefaultContextTest()
+	snowCtx.ChainID = chainID
+	dbManager := manager.NewMemDB(&version.Semantic{Major: 1})
+	// activate every upgrade from genesis
+	upgradeBytes := []byte(`[{"name":"multiEntry","timestamp":0},{"name":"variableLengthData","timestamp":0},{"name":"merkleRoot","timestamp":0},{"name":"signedData","timestamp":0},{"name":"millisecondTimestamp","timestamp":0}]`)
+	require.NoError(vm.Initialize(ctx, snowCtx, dbManager, []byte{1}, upgradeBytes, nil, nil, nil, vmSender))
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(lastAccepted, 1, [][]byte{{1, 2}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	// accepted data is found at the height and time of its block
+	response, err := getTimestamp([]byte{1, 2})
+	require.NoError(err)
+	require.Equal(&timestampvm.TimestampResponse{
+		Accepted:    true,
+		BlkID:       blk.ID(),
+		Height:      1,
+		Timestamp:   blk.Timestamp().Unix(),
+		TimestampMs: blk.Timestamp().UnixMilli(),
+	}, response)
+
+	// data that wasn't accepted isn't found
+	response, err = getTimestamp([]byte{3})
+	require.NoError(err)
+	require.False(response.Accepted)
+
+	// failed requests return an error
+	client = NewClient(&common.SenderTest{
+		SendCrossChainAppRequestF: func(_ context.Context, toChainID ids.ID, requestID uint32, _ []byte) {
+			go client.HandleRequestFailed(toChainID, requestID)
+		},
+	}, chainID)
+	_, err = client.GetTimestamp(ctx, []byte{1, 2})
+	require.ErrorIs(err, errRequestFailed)
+
+	// responses to unknown requests aren't handled
+	require.False(client.HandleResponse(chainID, 100, nil))
+	require.False(client.HandleResponse(otherChainID, 0, nil))
+}
@@ -106,6 +106,14 @@ echo -n "{}" >/tmp/.config
 
 ############################
 
+############################
+
+echo "creating upgrade file"
+# activate every upgrade from genesis
+echo -n '[{"name":"multiEntry","timestamp":0},{"name":"variableLengthData","timestamp":0},{"name":"merkleRoot","timestamp":0},{"name":"signedData","timestamp":0},{"name":"millisecondTimestamp","timestamp":0}]' >/tmp/.upgrade
+
+############################
+
 ############################
 echo "building e2e.test"
 # to install the ginkgo binary (required for test build and run)
@@ -151,6 +159,7 @@ echo "running e2e tests"
   --avalanchego-plugin-dir=${AVALANCHEGO_PLUGIN_DIR} \
   --vm-genesis-path=/tmp/.genesis \
   --vm-config-path=/tmp/.config \
+  --vm-upgrade-path=/tmp/.upgrade \
   --output-path=/tmp/avalanchego-${avalanche_version}/output.yaml \
   --mode=${MODE}
 STATUS=$?
@@ -14,6 +14,7 @@ import (
 
 	runner_sdk "github.com/ava-labs/avalanche-network-runner/client"
 	"github.com/ava-labs/avalanche-network-runner/rpcpb"
+	"github.com/ava-labs/avalanchego/api/health"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/hashing"
 	"github.com/ava-labs/avalanchego/utils/logging"
@@ -43,6 +44,7 @@ var (
 
 	vmGenesisPath string
 	vmConfigPath  string
+	vmUpgradePath string
 	outputPath    string
 
 	mode string
@@ -104,6 +106,13 @@ func init() {
 		"VM configfile path",
 	)
 
+	flag.StringVar(
+		&vmUpgradePath,
+		"vm-upgrade-path",
+		"",
+		"VM upgrade file path",
+	)
+
 	flag.StringVar(
 		&outputPath,
 		"output-path",
@@ -171,9 +180,10 @@ var _ = ginkgo.BeforeSuite(func() {
 			runner_sdk.WithBlockchainSpecs(
 				[]*rpcpb.BlockchainSpec{
 					{
-						VmName:      vmName,
-						Genesis:     vmGenesisPath,
-						ChainConfig: vmConfigPath,
+						VmName:         vmName,
+						Genesis:        vmGenesisPath,
+						ChainConfig:    vmConfigPath,
+						NetworkUpgrade: vmUpgradePath,
 					},
 				},
 			),
@@ -194,8 +204,6 @@ var _ = ginkgo.BeforeSuite(func() {
 		outf("{{green}}successfully started:{{/}} %+v\n", resp.ClusterInfo.NodeNames)
 	})
 
-	// TODO: network runner health should imply custom VM healthiness
-	// or provide a separate API for custom VM healthiness
 	// "start" is async, so wait some time for cluster health
 	outf("\n{{magenta}}waiting for all vms to report healthy...{{/}}: %s\n", vmID)
 	for {
@@ -257,6 +265,16 @@ done:
 		outf("{{blue}}avalanche timestampvm RPC:{{/}} %q\n", rpcEP)
 	}
 
+	// node health includes the health check of the timestampvm chain
+	outf("\n{{magenta}}waiting for timestampvm to report healthy...{{/}}\n")
+	for _, u := range uris {
+		cctx, ccancel := context.WithTimeout(context.Background(), 2*time.Minute)
+		healthy, err := health.AwaitHealthy(cctx, health.NewClient(u), time.Second, nil)
+		ccancel()
+		gomega.Expect(err).Should(gomega.BeNil())
+		gomega.Expect(healthy).Should(gomega.BeTrue())
+	}
+
 	pid := os.Getpid()
 	outf("{{blue}}{{bold}}writing output %q with PID %d{{/}}\n", outputPath, pid)
 	ci := clusterInfo{
@@ -316,7 +334,8 @@ var _ = ginkgo.Describe("[ProposeBlock]", func() {
 			timestamp, data, height, id, _, err := cli.GetBlock(context.Background(), nil)
 			gid = id
 			gomega.Ω(timestamp).Should(gomega.Equal(uint64(0)))
-			gomega.Ω(data).Should(gomega.Equal(timestampvm.BytesToData([]byte("e2e"))))
+			genesisData := timestampvm.BytesToData([]byte("e2e"))
+			gomega.Ω(data).Should(gomega.Equal([][]byte{genesisData[:]}))
 			gomega.Ω(height).Should(gomega.Equal(uint64(0)))
 			gomega.Ω(err).Should(gomega.BeNil())
 		}
@@ -328,13 +347,14 @@ var _ = ginkgo.Describe("[ProposeBlock]", func() {
 		return
 	}
 
-	data := timestampvm.BytesToData(hashing.ComputeHash256([]byte("test")))
+	data := hashing.ComputeHash256([]byte("test"))
 	now := time.Now().Unix()
 	ginkgo.It("create new block", func() {
 		cli := instances[0].cli
-		success, err := cli.ProposeBlock(context.Background(), data)
+		proposalID, success, err := cli.ProposeBlock(context.Background(), data)
 		gomega.Ω(success).Should(gomega.BeTrue())
 		gomega.Ω(err).Should(gomega.BeNil())
+		gomega.Ω(proposalID).Should(gomega.Equal(timestampvm.ProposalID(data)))
 	})
 
 	ginkgo.It("confirm block processed on all nodes", func() {
@@ -348,7 +368,7 @@ var _ = ginkgo.Describe("[ProposeBlock]", func() {
 					continue
 				}
 				gomega.Ω(uint64(now)-5 < timestamp).Should(gomega.BeTrue())
-				gomega.Ω(bdata).Should(gomega.Equal(data))
+				gomega.Ω(bdata).Should(gomega.Equal([][]byte{data}))
 				gomega.Ω(height).Should(gomega.Equal(uint64(1)))
 				gomega.Ω(pid).Should(gomega.Equal(gid))
 				gomega.Ω(err).Should(gomega.BeNil())
@@ -14,10 +14,10 @@ import (
 // Client defines timestampvm client operations.
 type Client interface {
 	// ProposeBlock submits data for a block
-	ProposeBlock(ctx context.Context, data [timestampvm.DataLen]byte) (bool, error)
+	ProposeBlock(ctx context.Context, data []byte) (bool, error)
 
 	// GetBlock fetches the contents of a block
-	GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [timestampvm.DataLen]byte, uint64, ids.ID, ids.ID, error)
+	GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [][]byte, uint64, ids.ID, ids.ID, error)
 }
 
 // New creates a new client object.
@@ -30,8 +30,8 @@ type client struct {
 	req *EndpointRequester
 }
 
-func (cli *client) ProposeBlock(ctx context.Context, data [timestampvm.DataLen]byte) (bool, error) {
-	bytes, err := formatting.Encode(formatting.Hex, data[:])
+func (cli *client) ProposeBlock(ctx context.Context, data []byte) (bool, error) {
+	bytes, err := formatting.Encode(formatting.Hex, data)
 	if err != nil {
 		return false, err
 	}
@@ -48,7 +48,7 @@ func (cli *client) ProposeBlock(ctx context.Context, data [timestampvm.DataLen]b
 	return resp.Success, nil
 }
 
-func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [timestampvm.DataLen]byte, uint64, ids.ID, ids.ID, error) {
+func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [][]byte, uint64, ids.ID, ids.ID, error) {
 	resp := new(timestampvm.GetBlockReply)
 	err := cli.req.SendRequest(ctx,
 		"getBlock",
@@ -56,11 +56,14 @@ func (cli *client) GetBlock(ctx context.Context, blockID *ids.ID) (uint64, [time
 		resp,
 	)
 	if err != nil {
-		return 0, [timestampvm.DataLen]byte{}, 0, ids.Empty, ids.Empty, err
+		return 0, nil, 0, ids.Empty, ids.Empty, err
 	}
-	bytes, err := formatting.Decode(formatting.Hex, resp.Data)
-	if err != nil {
-		return 0, [timestampvm.DataLen]byte{}, 0, ids.Empty, ids.Empty, err
+	entries := make([][]byte, len(resp.Data))
+	for i, data := range resp.Data {
+		entries[i], err = formatting.Decode(formatting.Hex, data)
+		if err != nil {
+			return 0, nil, 0, ids.Empty, ids.Empty, err
+		}
 	}
-	return uint64(resp.Timestamp), timestampvm.BytesToData(bytes), uint64(resp.Height), resp.ID, resp.ParentID, nil
+	return uint64(resp.Timestamp), entries, uint64(resp.Height), resp.ID, resp.ParentID, nil
 }
@@ -71,8 +71,8 @@ func (t *timestampvmLoadWorker) AddLoad(ctx context.Context, quit <-chan struct{
 		default:
 		}
 
-		data := [timestampvm.DataLen]byte{}
-		_, err := rand.Read(data[:])
+		data := make([]byte, timestampvm.DataLen)
+		_, err := rand.Read(data)
 		if err != nil {
 			return fmt.Errorf("failed to read random data: %w", err)
 		}
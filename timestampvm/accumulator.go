@@ -0,0 +1,176 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"math/bits"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/ava-labs/avalanchego/utils/json"
+)
+
+// Prefixes of the hashed values, so a piece of data can't be mistaken for
+// a node of the accumulator
+const (
+	leafHashPrefix byte = iota
+	nodeHashPrefix
+)
+
+var (
+	errIndexOutOfRange = errors.New("data index is out of the accumulator's range")
+	errBadProofLength  = errors.New("proof has the wrong number of hashes")
+	errBadProof        = errors.New("proof doesn't lead to the root")
+	errBadPeaks        = errors.New("accumulator has the wrong number of peaks for its size")
+)
+
+// Accumulator is a Merkle mountain range over the pieces of data accepted by
+// the chain, in the order they were accepted.
+// It's made of perfect binary Merkle trees of decreasing heights, one for
+// each bit set in its size, whose roots are its peaks.
+type Accumulator struct {
+	// Number of pieces of data in the accumulator
+	Size uint64 `serialize:"true" json:"size"`
+	// Roots of the trees, from the tallest to the smallest
+	Peaks []ids.ID `serialize:"true" json:"peaks"`
+}
+
+// Append adds [data] to the accumulator
+func (a *Accumulator) Append(data []byte) {
+	// The new leaf is merged with the trees of the same height, which are the
+	// ones of the trailing bits set in the size
+	node := leafHash(data)
+	for size := a.Size; size&1 == 1; size >>= 1 {
+		left := a.Peaks[len(a.Peaks)-1]
+		a.Peaks = a.Peaks[:len(a.Peaks)-1]
+		node = nodeHash(left, node)
+	}
+	a.Peaks = append(a.Peaks, node)
+	a.Size++
+}
+
+// Root returns the root of the accumulator, which commits to every piece of
+// data in it. The root of an empty accumulator is [ids.Empty].
+func (a *Accumulator) Root() ids.ID {
+	return bagPeaks(a.Peaks)
+}
+
+// with returns a copy of the accumulator with [entries] appended, leaving
+// the accumulator as it is
+func (a *Accumulator) with(entries [][]byte) *Accumulator {
+	peaks := make([]ids.ID, len(a.Peaks), len(a.Peaks)+1)
+	copy(peaks, a.Peaks)
+	acc := &Accumulator{
+		Size:  a.Size,
+		Peaks: peaks,
+	}
+	for _, data := range entries {
+		acc.Append(data)
+	}
+	return acc
+}
+
+// verify returns nil iff the accumulator has a peak for each of its trees
+func (a *Accumulator) verify() error {
+	if len(a.Peaks) != bits.OnesCount64(a.Size) {
+		return errBadPeaks
+	}
+	return nil
+}
+
+// InclusionProof proves that a piece of data is in an accumulator
+type InclusionProof struct {
+	// Size of the accumulator
+	Size json.Uint64 `json:"size"`
+	// Index of the data in the accumulator
+	Index json.Uint64 `json:"index"`
+	// Siblings of the nodes from the leaf of the data up to the root of its
+	// tree, bottom up
+	Path []ids.ID `json:"path"`
+	// Peaks of the accumulator
+	Peaks []ids.ID `json:"peaks"`
+}
+
+// Verify returns nil iff this proof shows that [data] is in the accumulator
+// whose root is [root]
+func (p *InclusionProof) Verify(data []byte, root ids.ID) error {
+	size, index := uint64(p.Size), uint64(p.Index)
+	if index >= size {
+		return errIndexOutOfRange
+	}
+	peak, height, start := treeOf(index, size)
+	if len(p.Peaks) != bits.OnesCount64(size) || len(p.Path) != height {
+		return errBadProofLength
+	}
+
+	// Hash up from the leaf to the root of its tree
+	node := leafHash(data)
+	position := index - start
+	for _, sibling := range p.Path {
+		if position&1 == 0 {
+			node = nodeHash(node, sibling)
+		} else {
+			node = nodeHash(sibling, node)
+		}
+		position >>= 1
+	}
+	if node != p.Peaks[peak] || bagPeaks(p.Peaks) != root {
+		return errBadProof
+	}
+	return nil
+}
+
+// treeOf returns which tree, from the tallest, holds the data at [index] in
+// an accumulator of [size], along with the height of the tree and the index
+// of its first piece of data. [index] must be less than [size].
+func treeOf(index, size uint64) (int, int, uint64) {
+	var (
+		tree  = 0
+		start = uint64(0)
+	)
+	for height := 63; height >= 0; height-- {
+		treeSize := uint64(1) << height
+		if size&treeSize == 0 {
+			continue
+		}
+		if index < start+treeSize {
+			return tree, height, start
+		}
+		tree++
+		start += treeSize
+	}
+	return tree, 0, start
+}
+
+// bagPeaks folds [peaks] into a single root, from the smallest tree to the
+// tallest
+func bagPeaks(peaks []ids.ID) ids.ID {
+	if len(peaks) == 0 {
+		return ids.Empty
+	}
+	root := peaks[len(peaks)-1]
+	for i := len(peaks) - 2; i >= 0; i-- {
+		root = nodeHash(peaks[i], root)
+	}
+	return root
+}
+
+// leafHash returns the hash of the leaf holding [data]
+func leafHash(data []byte) ids.ID {
+	bytes := make([]byte, 0, 1+len(data))
+	bytes = append(bytes, leafHashPrefix)
+	bytes = append(bytes, data...)
+	return hashing.ComputeHash256Array(bytes)
+}
+
+// nodeHash returns the hash of the node whose children are [left] and
+// [right]
+func nodeHash(left, right ids.ID) ids.ID {
+	bytes := make([]byte, 0, 1+2*len(left))
+	bytes = append(bytes, nodeHashPrefix)
+	bytes = append(bytes, left[:]...)
+	bytes = append(bytes, right[:]...)
+	return hashing.ComputeHash256Array(bytes)
+}
@@ -0,0 +1,199 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/json"
+)
+
+const (
+	accumulatorSizeByte byte = iota
+	accumulatorHeightByte
+	accumulatorNodeByte
+)
+
+// persists the size of the accumulator of the last accepted block with this
+// key
+var accumulatorSizeKey = []byte{accumulatorSizeByte}
+
+var _ AccumulatorState = &accumulatorState{}
+
+// AccumulatorState defines methods to manage the accumulator over the
+// accepted data, and its size at each accepted block.
+type AccumulatorState interface {
+	// GetAccumulatorSizeAtHeight returns the size of the accumulator after
+	// the block accepted at [height]
+	GetAccumulatorSizeAtHeight(height uint64) (uint64, error)
+	// GetAccumulator returns the accumulator as it was when it had [size]
+	// pieces of data
+	GetAccumulator(size uint64) (*Accumulator, error)
+	// GetInclusionProof returns the proof that the data at [index] is in the
+	// accumulator as it was when it had [size] pieces of data
+	GetInclusionProof(index, size uint64) (*InclusionProof, error)
+
+	// AppendToAccumulator adds [entries], the data of the block accepted at
+	// [height], to the accumulator
+	AppendToAccumulator(height uint64, entries [][]byte) error
+	// SetAccumulator makes [acc] the accumulator after the block accepted at
+	// [height]. Only the peaks of [acc] are stored, so the data before it
+	// can't be proven.
+	SetAccumulator(height uint64, acc *Accumulator) error
+	// ResetAccumulator empties the accumulator
+	ResetAccumulator() error
+}
+
+// accumulatorState implements AccumulatorState interface with a database.
+// Each node of the accumulator is stored by its height in its tree and its
+// index among the nodes of that height, so nodes are never overwritten as
+// data is added.
+type accumulatorState struct {
+	accumulatorDB database.Database
+}
+
+func NewAccumulatorState(db database.Database) AccumulatorState {
+	return &accumulatorState{
+		accumulatorDB: db,
+	}
+}
+
+func accumulatorHeightKey(height uint64) []byte {
+	return append([]byte{accumulatorHeightByte}, database.PackUInt64(height)...)
+}
+
+func accumulatorNodeKey(height int, index uint64) []byte {
+	return append([]byte{accumulatorNodeByte, byte(height)}, database.PackUInt64(index)...)
+}
+
+func (s *accumulatorState) GetAccumulatorSizeAtHeight(height uint64) (uint64, error) {
+	return database.GetUInt64(s.accumulatorDB, accumulatorHeightKey(height))
+}
+
+func (s *accumulatorState) GetAccumulator(size uint64) (*Accumulator, error) {
+	peaks, err := s.getPeaks(size)
+	if err != nil {
+		return nil, err
+	}
+	return &Accumulator{
+		Size:  size,
+		Peaks: peaks,
+	}, nil
+}
+
+func (s *accumulatorState) GetInclusionProof(index, size uint64) (*InclusionProof, error) {
+	if index >= size {
+		return nil, errIndexOutOfRange
+	}
+	peaks, err := s.getPeaks(size)
+	if err != nil {
+		return nil, err
+	}
+
+	// The siblings of the nodes from the leaf up to the root of its tree
+	_, treeHeight, _ := treeOf(index, size)
+	path := make([]ids.ID, treeHeight)
+	for height := range path {
+		path[height], err = database.GetID(s.accumulatorDB, accumulatorNodeKey(height, (index>>height)^1))
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &InclusionProof{
+		Size:  json.Uint64(size),
+		Index: json.Uint64(index),
+		Path:  path,
+		Peaks: peaks,
+	}, nil
+}
+
+func (s *accumulatorState) AppendToAccumulator(height uint64, entries [][]byte) error {
+	size, err := s.getSize()
+	if err != nil {
+		return err
+	}
+
+	for _, data := range entries {
+		// Store the new leaf, and the nodes it completes up to the root of its
+		// tree
+		node := leafHash(data)
+		nodeHeight, index := 0, size
+		if err := database.PutID(s.accumulatorDB, accumulatorNodeKey(nodeHeight, index), node); err != nil {
+			return err
+		}
+		for ; index&1 == 1; index >>= 1 {
+			left, err := database.GetID(s.accumulatorDB, accumulatorNodeKey(nodeHeight, index-1))
+			if err != nil {
+				return err
+			}
+			node = nodeHash(left, node)
+			nodeHeight++
+			if err := database.PutID(s.accumulatorDB, accumulatorNodeKey(nodeHeight, index>>1), node); err != nil {
+				return err
+			}
+		}
+		size++
+	}
+	return s.setSize(height, size)
+}
+
+func (s *accumulatorState) SetAccumulator(height uint64, acc *Accumulator) error {
+	start := uint64(0)
+	for treeHeight, peak := 63, 0; treeHeight >= 0; treeHeight-- {
+		treeSize := uint64(1) << treeHeight
+		if acc.Size&treeSize == 0 {
+			continue
+		}
+		if err := database.PutID(s.accumulatorDB, accumulatorNodeKey(treeHeight, start>>treeHeight), acc.Peaks[peak]); err != nil {
+			return err
+		}
+		start += treeSize
+		peak++
+	}
+	return s.setSize(height, acc.Size)
+}
+
+func (s *accumulatorState) ResetAccumulator() error {
+	return database.PutUInt64(s.accumulatorDB, accumulatorSizeKey, 0)
+}
+
+// getSize returns the size of the accumulator of the last accepted block
+func (s *accumulatorState) getSize() (uint64, error) {
+	size, err := database.GetUInt64(s.accumulatorDB, accumulatorSizeKey)
+	if err == database.ErrNotFound {
+		return 0, nil
+	}
+	return size, err
+}
+
+// setSize persists [size] as the size of the accumulator of the last accepted
+// block, accepted at [height]
+func (s *accumulatorState) setSize(height uint64, size uint64) error {
+	if err := database.PutUInt64(s.accumulatorDB, accumulatorHeightKey(height), size); err != nil {
+		return err
+	}
+	return database.PutUInt64(s.accumulatorDB, accumulatorSizeKey, size)
+}
+
+// getPeaks returns the peaks of the accumulator as it was when it had [size]
+// pieces of data
+func (s *accumulatorState) getPeaks(size uint64) ([]ids.ID, error) {
+	var (
+		peaks = make([]ids.ID, 0, 64)
+		start = uint64(0)
+	)
+	for treeHeight := 63; treeHeight >= 0; treeHeight-- {
+		treeSize := uint64(1) << treeHeight
+		if size&treeSize == 0 {
+			continue
+		}
+		peak, err := database.GetID(s.accumulatorDB, accumulatorNodeKey(treeHeight, start>>treeHeight))
+		if err != nil {
+			return nil, err
+		}
+		peaks = append(peaks, peak)
+		start += treeSize
+	}
+	return peaks, nil
+}
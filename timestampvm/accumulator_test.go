@@ -0,0 +1,83 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"testing"
+
+	"github.com/ava-labs/avalanchego/database/memdb"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// require that the persisted accumulator matches the in-memory one at every
+// size, and proves every piece of data in it
+func TestAccumulatorState(t *testing.T) {
+	require := require.New(t)
+
+	state := NewAccumulatorState(memdb.New())
+	acc := &Accumulator{}
+	require.Equal(ids.Empty, acc.Root())
+
+	roots := make([]ids.ID, 0, 20)
+	for height := uint64(0); height < 10; height++ {
+		entries := [][]byte{{byte(height)}, {byte(height), 1}}
+		require.NoError(state.AppendToAccumulator(height, entries))
+		for _, data := range entries {
+			acc.Append(data)
+			roots = append(roots, acc.Root())
+		}
+		size, err := state.GetAccumulatorSizeAtHeight(height)
+		require.NoError(err)
+		require.Equal(acc.Size, size)
+	}
+
+	for size := uint64(1); size <= 20; size++ {
+		stored, err := state.GetAccumulator(size)
+		require.NoError(err)
+		require.Equal(roots[size-1], stored.Root())
+
+		for index := uint64(0); index < size; index++ {
+			data := []byte{byte(index / 2)}
+			if index%2 == 1 {
+				data = append(data, 1)
+			}
+			proof, err := state.GetInclusionProof(index, size)
+			require.NoError(err)
+			require.NoError(proof.Verify(data, roots[size-1]))
+			require.ErrorIs(proof.Verify([]byte{0xff}, roots[size-1]), errBadProof)
+		}
+	}
+	_, err := state.GetInclusionProof(20, 20)
+	require.ErrorIs(err, errIndexOutOfRange)
+}
+
+// require that an accumulator synced from its peaks can be appended to
+func TestSetAccumulator(t *testing.T) {
+	require := require.New(t)
+
+	acc := &Accumulator{}
+	for i := 0; i < 11; i++ {
+		acc.Append([]byte{byte(i)})
+	}
+	require.NoError(acc.verify())
+
+	state := NewAccumulatorState(memdb.New())
+	require.NoError(state.SetAccumulator(5, acc))
+	entries := [][]byte{{11}, {12}}
+	require.NoError(state.AppendToAccumulator(6, entries))
+
+	expected := acc.with(entries)
+	stored, err := state.GetAccumulator(expected.Size)
+	require.NoError(err)
+	require.Equal(expected.Root(), stored.Root())
+
+	// the data after the synced accumulator can be proven
+	proof, err := state.GetInclusionProof(12, expected.Size)
+	require.NoError(err)
+	require.NoError(proof.Verify([]byte{12}, expected.Root()))
+
+	// peaks must match the size
+	require.ErrorIs((&Accumulator{Size: 3, Peaks: acc.Peaks[:1]}).verify(), errBadPeaks)
+}
@@ -0,0 +1,117 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"net/http"
+
+	"go.uber.org/zap"
+
+	"github.com/ava-labs/avalanchego/api"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/json"
+)
+
+const (
+	// AdminEndpoint is the path extension, relative to the chain's API, of
+	// the admin API
+	AdminEndpoint = "/admin"
+	// AdminName is the name the admin API methods are prefixed with
+	AdminName = "admin"
+)
+
+var errBuildingPaused = errors.New("block building is paused")
+
+// AdminService is the API service letting operators inspect and control the
+// node at runtime. It's only served if the adminAPIEnabled config is set.
+type AdminService struct{ vm *VM }
+
+// GetMempoolReply is the reply from GetMempool
+type GetMempoolReply struct {
+	// Data (hex-encoded) in the mempool, oldest first
+	Data []string `json:"data"`
+	// Maximum number of pieces of data the mempool holds
+	MaxSize json.Uint32 `json:"maxSize"`
+	// Whether block building is paused
+	BuildingPaused bool `json:"buildingPaused"`
+}
+
+// GetMempool gets the data waiting in the mempool to be put into a block
+func (s *AdminService) GetMempool(_ *http.Request, _ *struct{}, reply *GetMempoolReply) error {
+	contents := s.vm.mempool.Contents()
+	reply.Data = make([]string, len(contents))
+	for i, data := range contents {
+		encoded, err := formatting.Encode(formatting.Hex, data)
+		if err != nil {
+			return err
+		}
+		reply.Data[i] = encoded
+	}
+	reply.MaxSize = json.Uint32(s.vm.mempool.MaxSize())
+	reply.BuildingPaused = s.vm.buildingPaused.Get()
+	return nil
+}
+
+// ClearMempoolReply is the reply from ClearMempool
+type ClearMempoolReply struct {
+	// Number of pieces of data dropped from the mempool
+	Cleared json.Uint32 `json:"cleared"`
+}
+
+// ClearMempool drops the data in the mempool. It has to be proposed again to
+// be timestamped.
+func (s *AdminService) ClearMempool(_ *http.Request, _ *struct{}, reply *ClearMempoolReply) error {
+	reply.Cleared = 0
+	for {
+		data, ok := s.vm.mempool.Pop()
+		if !ok {
+			break
+		}
+		s.vm.dropped.drop(data, dropCauseCleared, "cleared from the mempool by an operator")
+		reply.Cleared++
+	}
+	s.vm.snowCtx.Log.Info("cleared mempool",
+		zap.Uint32("cleared", uint32(reply.Cleared)),
+	)
+	return nil
+}
+
+// PauseBuilding stops this node from building blocks until ResumeBuilding is
+// called. The node still votes on the blocks built by the others, and keeps
+// adding proposed data to its mempool.
+func (s *AdminService) PauseBuilding(_ *http.Request, _ *struct{}, _ *api.EmptyReply) error {
+	s.vm.buildingPaused.Set(true)
+	s.vm.snowCtx.Log.Info("paused block building")
+	return nil
+}
+
+// ResumeBuilding lets this node build blocks again
+func (s *AdminService) ResumeBuilding(_ *http.Request, _ *struct{}, _ *api.EmptyReply) error {
+	s.vm.buildingPaused.Set(false)
+	s.vm.snowCtx.Log.Info("resumed block building")
+	if s.vm.mempool.Len() > 0 {
+		s.vm.NotifyBlockReady()
+	}
+	return nil
+}
+
+// SetLogLevelArgs are the arguments to SetLogLevel
+type SetLogLevelArgs struct {
+	// Level of the VM's logs, as in the logLevel config
+	Level string `json:"level"`
+}
+
+// SetLogLevel sets the level of the VM's logs, including the chain's logger,
+// until the node restarts
+func (s *AdminService) SetLogLevel(_ *http.Request, args *SetLogLevelArgs, _ *api.EmptyReply) error {
+	if err := setLogLevel(s.vm.snowCtx.Log, args.Level); err != nil {
+		return err
+	}
+	s.vm.config.LogLevel = args.Level
+	s.vm.snowCtx.Log.Info("set log level",
+		zap.String("level", args.Level),
+	)
+	return nil
+}
@@ -13,38 +13,103 @@ import (
 	"github.com/ava-labs/avalanchego/snow/choices"
 	"github.com/ava-labs/avalanchego/snow/consensus/snowman"
 	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/ava-labs/avalanchego/utils/units"
 )
 
 var (
 	errTimestampTooEarly = errors.New("block's timestamp is earlier than its parent's timestamp")
 	errDatabaseGet       = errors.New("error while retrieving data from database")
-	errTimestampTooLate  = errors.New("block's timestamp is more than 1 hour ahead of local time")
+	errTimestampTooLate  = errors.New("block's timestamp is too far ahead of local time")
+	errBadMilliseconds   = errors.New("block's timestamp has more than 999 milliseconds")
+	errNoEntries         = errors.New("block has no data")
+	errTooManyEntries    = fmt.Errorf("block has more than %d pieces of data", MaxBlockEntries)
+	errEmptyData         = errors.New("data is empty")
+	errDataTooLarge      = errors.New("data is too large")
+	errBlockTooLarge     = fmt.Errorf("block has more than %d bytes of data", MaxBlockDataSize)
+	errWrongBlockVersion = errors.New("block's version isn't the one scheduled at its timestamp")
+	errBadEntries        = errors.New("data doesn't fit the block version")
+	errWrongRoot         = errors.New("block's accumulator root doesn't match its data")
+	errBadSubmissions    = errors.New("block doesn't have a submission for each piece of data")
+	errBadSubmission     = errors.New("submission of unsigned data isn't empty")
+	errDuplicateNonce    = errors.New("block uses the nonce of a submitter more than once")
 
 	_ snowman.Block = &Block{}
 )
 
+const (
+	// MaxBlockEntries is the maximum number of pieces of data in a block
+	MaxBlockEntries = 1024
+	// MaxDataLen is the maximum length of a piece of data in a
+	// [BlockVersion2] block
+	MaxDataLen = 64 * units.KiB
+	// MaxBlockDataSize is the maximum total length of the data in a
+	// [BlockVersion2] block
+	MaxBlockDataSize = 128 * units.KiB
+)
+
 // Block is a block on the chain.
 // Each block contains:
 // 1) ParentID
 // 2) Height
 // 3) Timestamp
-// 4) A piece of data (a string)
+// 4) Pieces of data; a single one in [BlockVersion0] blocks, and a list
+// of them in later versions
+// 5) From [BlockVersion3], the root and size of the accumulator over the data
+// accepted up to and including this block
+// 6) From [BlockVersion4], the submission of each piece of data
+// 7) From [BlockVersion5], the milliseconds of the timestamp
 type Block struct {
-	PrntID ids.ID        `serialize:"true" json:"parentID"`  // parent's ID
-	Hght   uint64        `serialize:"true" json:"height"`    // This block's height. The genesis block is at height 0.
-	Tmstmp int64         `serialize:"true" json:"timestamp"` // Time this block was proposed at
-	Dt     [DataLen]byte `serialize:"true" json:"data"`      // Arbitrary data
+	PrntID ids.ID          `serialize:"true" json:"parentID"`                                   // parent's ID
+	Hght   uint64          `serialize:"true" json:"height"`                                     // This block's height. The genesis block is at height 0.
+	Tmstmp int64           `serialize:"true" json:"timestamp"`                                  // Time this block was proposed at, in Unix seconds
+	Dt     [DataLen]byte   `v0:"true" json:"data"`                                              // Arbitrary data, in [BlockVersion0] blocks
+	Dts    [][DataLen]byte `v1:"true" len:"1024" json:"entries"`                                // Arbitrary data, in [BlockVersion1] blocks. Bounded by [MaxBlockEntries].
+	Pylds  [][]byte        `v2:"true" v3:"true" v4:"true" v5:"true" len:"1024" json:"payloads"` // Arbitrary variable length data, from [BlockVersion2] blocks. Bounded by [MaxBlockEntries].
+	Rt     ids.ID          `v3:"true" v4:"true" v5:"true" json:"root"`                          // Root of the accumulator after this block, from [BlockVersion3] blocks
+	Sz     uint64          `v3:"true" v4:"true" v5:"true" json:"size"`                          // Size of the accumulator after this block, from [BlockVersion3] blocks
+	Sbmsns []Submission    `v4:"true" v5:"true" len:"1024" json:"submissions"`                  // Submission of each piece of data, from [BlockVersion4] blocks
+	Ms     uint16          `v5:"true" json:"milliseconds"`                                      // Milliseconds within the second of [Tmstmp], from [BlockVersion5] blocks
+
+	id      ids.ID         // hold this block's ID
+	bytes   []byte         // this block's encoded bytes
+	status  choices.Status // block's status
+	version uint16         // codec version this block is encoded with
+	vm      *VM            // the underlying VM reference, mostly used for state
+	acc     *Accumulator   // the accumulator after this block, once computed
+}
 
-	id     ids.ID         // hold this block's ID
-	bytes  []byte         // this block's encoded bytes
-	status choices.Status // block's status
-	vm     *VM            // the underlying VM reference, mostly used for state
+// parseBlock unmarshals [bytes] into a block of the version [bytes] are
+// encoded with, and initializes it with [status] and [vm]
+func parseBlock(bytes []byte, status choices.Status, vm *VM) (*Block, error) {
+	block := &Block{}
+	version, err := Codec.Unmarshal(bytes, block)
+	if err != nil {
+		return nil, err
+	}
+	block.version = version
+	block.Initialize(bytes, status, vm)
+	return block, nil
 }
 
 // Verify returns nil iff this block is valid.
 // To be valid, it must be that:
-// b.parent.Timestamp < b.Timestamp <= [local time] + 1 hour
+// b.parent.Timestamp < b.Timestamp <= [local time] + [MaxFutureBlockTime]
+// and b's version is the one scheduled at b.Timestamp
 func (b *Block) Verify(_ context.Context) error {
+	start := time.Now()
+	err := b.verify()
+	b.vm.metrics.observeVerify(start, err)
+	return err
+}
+
+// verify runs the checks of [Verify], and records this block as verified if
+// they pass
+func (b *Block) verify() error {
+	// Ensure [b] has the format of the upgrades active at its timestamp
+	if err := b.verifyVersion(); err != nil {
+		return err
+	}
+
 	// Get [b]'s parent
 	parentID := b.Parent()
 	parent, err := b.vm.getBlock(parentID)
@@ -61,23 +126,164 @@ func (b *Block) Verify(_ context.Context) error {
 		)
 	}
 
+	// Ensure [b]'s timestamp is valid
+	if b.Ms >= uint16(time.Second/time.Millisecond) {
+		return errBadMilliseconds
+	}
+
 	// Ensure [b]'s timestamp is after its parent's timestamp.
-	if b.Timestamp().Unix() < parent.Timestamp().Unix() {
+	if b.Timestamp().Before(parent.Timestamp()) {
 		return errTimestampTooEarly
 	}
 
-	// Ensure [b]'s timestamp is not more than an hour
-	// ahead of this node's time
-	if b.Timestamp().Unix() >= time.Now().Add(time.Hour).Unix() {
+	// Ensure [b]'s timestamp is not more than [MaxFutureBlockTime]
+	// ahead of this node's time, at the precision of [b]'s timestamp
+	maxTimestamp := time.Now().Add(b.vm.config.MaxFutureBlockTime.Duration).Truncate(b.precision())
+	if !b.Timestamp().Before(maxTimestamp) {
 		return errTimestampTooLate
 	}
 
+	// Ensure [b] holds some data, but not more than a block can hold
+	if err := b.verifyEntries(); err != nil {
+		return err
+	}
+
+	// Ensure [b] commits to the accumulator after its data
+	if err := b.verifyRoot(); err != nil {
+		return err
+	}
+
+	// Ensure the signed data of [b] is signed by its submitters, with nonces
+	// they haven't used before
+	if err := b.verifySubmissions(); err != nil {
+		return err
+	}
+
 	// Put that block to verified blocks in memory
-	b.vm.verifiedBlocks[b.ID()] = b
+	b.vm.addVerifiedBlock(b)
 
 	return nil
 }
 
+// verifyVersion returns nil iff this block's version is the one scheduled by
+// the network upgrades at its timestamp
+func (b *Block) verifyVersion() error {
+	if expectedVersion := b.vm.upgrades.BlockVersion(b.Timestamp()); b.version != expectedVersion {
+		return fmt.Errorf("%w: expected %d, found %d", errWrongBlockVersion, expectedVersion, b.version)
+	}
+	return nil
+}
+
+// verifyEntries returns nil iff the number and the size of the pieces of data
+// in this block are within the limits of its version
+func (b *Block) verifyEntries() error {
+	switch b.version {
+	case BlockVersion0:
+		return nil
+	case BlockVersion1:
+		return verifyNumEntries(len(b.Dts))
+	default:
+		if err := verifyNumEntries(len(b.Pylds)); err != nil {
+			return err
+		}
+		if b.version >= BlockVersion4 && len(b.Sbmsns) != len(b.Pylds) {
+			return errBadSubmissions
+		}
+		size := 0
+		for _, data := range b.Pylds {
+			if err := verifyDataLen(data, MaxDataLen); err != nil {
+				return err
+			}
+			size += len(data)
+		}
+		if size > MaxBlockDataSize {
+			return errBlockTooLarge
+		}
+		return nil
+	}
+}
+
+// verifyRoot returns nil iff this block commits to the accumulator over the
+// data accepted up to and including it. Blocks before [BlockVersion3] don't
+// commit to it.
+func (b *Block) verifyRoot() error {
+	if b.version < BlockVersion3 {
+		return nil
+	}
+	acc, err := b.vm.accumulatorAfter(b)
+	if err != nil {
+		return err
+	}
+	if root := acc.Root(); b.Rt != root || b.Sz != acc.Size {
+		return fmt.Errorf("%w: expected root %s of size %d, found root %s of size %d", errWrongRoot, root, acc.Size, b.Rt, b.Sz)
+	}
+	return nil
+}
+
+// verifySubmissions returns nil iff each signed piece of data in this block
+// is signed by its submitter, with a nonce used only once, and the submissions
+// of the unsigned data are empty
+func (b *Block) verifySubmissions() error {
+	usedNonces := make(map[nonceKey]struct{}, len(b.Sbmsns))
+	for i := range b.Sbmsns {
+		sub := &b.Sbmsns[i]
+		if !sub.IsSigned() {
+			if *sub != (Submission{}) {
+				return errBadSubmission
+			}
+			continue
+		}
+
+		if err := sub.Verify(b.vm.snowCtx.ChainID, b.Pylds[i]); err != nil {
+			return fmt.Errorf("invalid signature of data %d: %w", i, err)
+		}
+		key := nonceKey{signer: sub.Signer, nonce: sub.Nonce}
+		if _, ok := usedNonces[key]; ok {
+			return errDuplicateNonce
+		}
+		usedNonces[key] = struct{}{}
+		used, err := b.vm.isNonceUsed(b.Parent(), sub.Signer, sub.Nonce)
+		if err != nil {
+			return err
+		}
+		if used {
+			return fmt.Errorf("%w: nonce %d of %s", errNonceUsed, sub.Nonce, sub.Signer)
+		}
+	}
+	return nil
+}
+
+// fitsBlockVersion returns true if [data] can be put into a block of
+// [version]. Blocks before [BlockVersion2] only hold [DataLen] bytes long data.
+func fitsBlockVersion(data []byte, version uint16) bool {
+	return version >= BlockVersion2 || len(data) == DataLen
+}
+
+// verifyNumEntries returns nil iff a block can hold [numEntries] pieces of data
+func verifyNumEntries(numEntries int) error {
+	switch {
+	case numEntries == 0:
+		return errNoEntries
+	case numEntries > MaxBlockEntries:
+		return errTooManyEntries
+	default:
+		return nil
+	}
+}
+
+// verifyDataLen returns nil iff [data] is not empty and at most [maxLen]
+// bytes long
+func verifyDataLen(data []byte, maxLen int) error {
+	switch {
+	case len(data) == 0:
+		return errEmptyData
+	case len(data) > maxLen:
+		return fmt.Errorf("%w: %d bytes > %d bytes", errDataTooLarge, len(data), maxLen)
+	default:
+		return nil
+	}
+}
+
 // Initialize sets [b.bytes] to [bytes], [b.id] to hash([b.bytes]),
 // [b.status] to [status] and [b.vm] to [vm]
 func (b *Block) Initialize(bytes []byte, status choices.Status, vm *VM) {
@@ -90,6 +296,11 @@ func (b *Block) Initialize(bytes []byte, status choices.Status, vm *VM) {
 // Accept sets this block's status to Accepted and sets lastAccepted to this
 // block's ID and saves this info to b.vm.DB
 func (b *Block) Accept(_ context.Context) error {
+	start := time.Now()
+	defer func() {
+		b.vm.metrics.acceptDuration.Observe(time.Since(start).Seconds())
+	}()
+
 	b.SetStatus(choices.Accepted) // Change state of this block
 	blkID := b.ID()
 
@@ -103,11 +314,54 @@ func (b *Block) Accept(_ context.Context) error {
 		return err
 	}
 
+	// Index this block by its height
+	if err := b.vm.state.SetBlockIDAtHeight(b.Height(), blkID); err != nil {
+		return err
+	}
+
+	// Add the data of this block to the accumulator
+	if err := b.vm.state.AppendToAccumulator(b.Height(), b.Entries()); err != nil {
+		return err
+	}
+
+	// Index this block by the data it holds
+	if err := b.vm.state.IndexData(b); err != nil {
+		return err
+	}
+
+	// Index this block by its timestamp
+	if err := b.vm.state.IndexTimestamp(b); err != nil {
+		return err
+	}
+
+	// Record the nonces used by the submitters of this block's data
+	if err := b.vm.state.IndexNonces(b); err != nil {
+		return err
+	}
+
+	// Delete the rejected blocks that are too far behind this block
+	if err := b.vm.pruneRejectedBlocks(b.Height()); err != nil {
+		return err
+	}
+
 	// Delete this block from verified blocks as it's accepted
-	delete(b.vm.verifiedBlocks, b.ID())
+	b.vm.removeVerifiedBlock(b)
+
+	// The data of this block no longer needs to be put into a block.
+	// This block may have been built by another node, so its data may still
+	// be in our mempool.
+	for _, data := range b.Entries() {
+		b.vm.mempool.Remove(data)
+	}
 
 	// Commit changes to database
-	return b.vm.state.Commit()
+	if err := b.vm.state.Commit(); err != nil {
+		return err
+	}
+	b.vm.metrics.blocksAccepted.Inc()
+
+	// Push this block to the subscribers of block events
+	return b.vm.events.publish(choices.Accepted, b)
 }
 
 // Reject sets this block's status to Rejected and saves the status in state
@@ -117,10 +371,21 @@ func (b *Block) Reject(_ context.Context) error {
 	if err := b.vm.state.PutBlock(b); err != nil {
 		return err
 	}
+	// Keep this block until it's too far behind the last accepted block
+	if err := b.vm.state.IndexRejectedBlock(b); err != nil {
+		return err
+	}
 	// Delete this block from verified blocks as it's rejected
-	delete(b.vm.verifiedBlocks, b.ID())
+	b.vm.removeVerifiedBlock(b)
+	// The data of this block is lost unless it's proposed again
+	b.vm.dropped.dropAll(b.Entries(), dropCauseRejected, fmt.Sprintf("block %s was rejected", b.ID()))
 	// Commit changes to database
-	return b.vm.state.Commit()
+	if err := b.vm.state.Commit(); err != nil {
+		return err
+	}
+	b.vm.metrics.blocksRejected.Inc()
+	// Push this block to the subscribers of rejected blocks
+	return b.vm.events.publish(choices.Rejected, b)
 }
 
 // ID returns the ID of this block
@@ -133,7 +398,17 @@ func (b *Block) Parent() ids.ID { return b.PrntID }
 func (b *Block) Height() uint64 { return b.Hght }
 
 // Timestamp returns this block's time. The genesis block has time 0.
-func (b *Block) Timestamp() time.Time { return time.Unix(b.Tmstmp, 0) }
+func (b *Block) Timestamp() time.Time {
+	return time.Unix(b.Tmstmp, int64(b.Ms)*int64(time.Millisecond))
+}
+
+// precision returns the precision of this block's timestamp
+func (b *Block) precision() time.Duration {
+	if b.version >= BlockVersion5 {
+		return time.Millisecond
+	}
+	return time.Second
+}
 
 // Status returns the status of this block
 func (b *Block) Status() choices.Status { return b.status }
@@ -141,8 +416,28 @@ func (b *Block) Status() choices.Status { return b.status }
 // Bytes returns the byte repr. of this block
 func (b *Block) Bytes() []byte { return b.bytes }
 
-// Data returns the data of this block
-func (b *Block) Data() [DataLen]byte { return b.Dt }
+// Version returns the codec version this block is encoded with
+func (b *Block) Version() uint16 { return b.version }
+
+// Entries returns the pieces of data in this block
+func (b *Block) Entries() [][]byte {
+	switch b.version {
+	case BlockVersion0:
+		return [][]byte{b.Dt[:]}
+	case BlockVersion1:
+		entries := make([][]byte, len(b.Dts))
+		for i := range b.Dts {
+			entries[i] = b.Dts[i][:]
+		}
+		return entries
+	default:
+		return b.Pylds
+	}
+}
+
+// Submissions returns the submission of each piece of data in this block.
+// Blocks before [BlockVersion4] have no submissions.
+func (b *Block) Submissions() []Submission { return b.Sbmsns }
 
 // SetStatus sets the status of this block
 func (b *Block) SetStatus(status choices.Status) { b.status = status }
@@ -4,32 +4,71 @@
 package timestampvm
 
 import (
+	"bytes"
+
 	"github.com/ava-labs/avalanchego/cache"
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/utils/hashing"
 )
 
 const (
 	lastAcceptedByte byte = iota
 )
 
-const (
-	// maximum block capacity of the cache
-	blockCacheSize = 8192
-)
-
 // persists lastAccepted block IDs with this key
 var lastAcceptedKey = []byte{lastAcceptedByte}
 
 var _ BlockState = &blockState{}
 
-// BlockState defines methods to manage state with Blocks and LastAcceptedIDs.
+// BlockState defines methods to manage state with Blocks, LastAcceptedIDs,
+// the IDs of the accepted blocks by height, by the data they hold and by
+// timestamp, and the rejected blocks to prune.
 type BlockState interface {
 	GetBlock(blkID ids.ID) (*Block, error)
 	PutBlock(blk *Block) error
 	GetLastAccepted() (ids.ID, error)
 	SetLastAccepted(ids.ID) error
+
+	GetBlockIDAtHeight(height uint64) (ids.ID, error)
+	SetBlockIDAtHeight(height uint64, blkID ids.ID) error
+
+	// GetBlockIDByData returns the ID of the earliest accepted block holding
+	// [data]
+	GetBlockIDByData(data []byte) (ids.ID, error)
+	// GetBlockIDByDataID returns the ID of the earliest accepted block
+	// holding the data whose hash is [dataID]
+	GetBlockIDByDataID(dataID ids.ID) (ids.ID, error)
+	// IndexData records the accepted block [blk] as holding its data, unless
+	// an earlier block holding the same data is recorded already
+	IndexData(blk *Block) error
+
+	// GetBlockIDsByTime returns the IDs of up to [limit] accepted blocks, in
+	// height order, from the block at [fromHeight] with the timestamp
+	// [fromTimestamp] on, whose timestamps are before [endTimestamp]
+	GetBlockIDsByTime(fromTimestamp int64, fromHeight uint64, endTimestamp int64, limit int) ([]ids.ID, error)
+	// GetBlockIDAfter returns the ID of the earliest accepted block whose
+	// timestamp is after [timestamp]
+	GetBlockIDAfter(timestamp int64) (ids.ID, error)
+	// IndexTimestamp records the accepted block [blk] by its timestamp
+	IndexTimestamp(blk *Block) error
+
+	// IsNonceUsed returns true if [nonce] of [signer] was used by an
+	// accepted block
+	IsNonceUsed(signer ids.ShortID, nonce uint64) (bool, error)
+	// IndexNonces records the nonces used by the submitters of the data of
+	// the accepted block [blk]
+	IndexNonces(blk *Block) error
+
+	// IndexRejectedBlock records that the rejected block [blk] is to be pruned
+	IndexRejectedBlock(blk *Block) error
+	// IndexStoredRejectedBlocks records that the rejected blocks stored
+	// before rejected blocks were indexed are to be pruned
+	IndexStoredRejectedBlocks() error
+	// PruneRejectedBlocks deletes the indexed rejected blocks at heights up to
+	// [maxHeight]
+	PruneRejectedBlocks(maxHeight uint64) error
 }
 
 // blockState implements BlocksState interface with database and cache.
@@ -39,6 +78,17 @@ type blockState struct {
 	// block database
 	blockDB      database.Database
 	lastAccepted ids.ID
+	// height --> accepted block ID database
+	heightDB database.Database
+	// height + rejected block ID database, in height order
+	rejectedDB database.Database
+	// hash of data --> earliest accepted block ID holding the data database
+	dataDB database.Database
+	// timestamp + height --> accepted block ID database, in height order as
+	// accepted blocks aren't earlier than their parent
+	timeDB database.Database
+	// signer + nonce --> accepted block ID using the nonce database
+	nonceDB database.Database
 
 	// vm reference
 	vm *VM
@@ -50,12 +100,18 @@ type blkWrapper struct {
 	Status choices.Status `serialize:"true"`
 }
 
-// NewBlockState returns BlockState with a new cache and given db
-func NewBlockState(db database.Database, vm *VM) BlockState {
+// NewBlockState returns BlockState with a new cache, holding at most the
+// configured number of blocks, and given dbs
+func NewBlockState(db database.Database, heightDB database.Database, rejectedDB database.Database, dataDB database.Database, timeDB database.Database, nonceDB database.Database, vm *VM) BlockState {
 	return &blockState{
-		blkCache: &cache.LRU[ids.ID, *Block]{Size: blockCacheSize},
-		blockDB:  db,
-		vm:       vm,
+		blkCache:   &cache.LRU[ids.ID, *Block]{Size: vm.config.BlockCacheSize},
+		blockDB:    db,
+		heightDB:   heightDB,
+		rejectedDB: rejectedDB,
+		dataDB:     dataDB,
+		timeDB:     timeDB,
+		nonceDB:    nonceDB,
+		vm:         vm,
 	}
 }
 
@@ -63,6 +119,7 @@ func NewBlockState(db database.Database, vm *VM) BlockState {
 func (s *blockState) GetBlock(blkID ids.ID) (*Block, error) {
 	// Check if cache has this blkID
 	if blk, cached := s.blkCache.Get(blkID); cached {
+		s.vm.metrics.blockCacheHits.Inc()
 		// there is a key but value is nil, so return an error
 		if blk == nil {
 			return nil, database.ErrNotFound
@@ -70,6 +127,7 @@ func (s *blockState) GetBlock(blkID ids.ID) (*Block, error) {
 		// We found it return the block in cache
 		return blk, nil
 	}
+	s.vm.metrics.blockCacheMisses.Inc()
 
 	// get block bytes from db with the blkID key
 	wrappedBytes, err := s.blockDB.Get(blkID[:])
@@ -90,15 +148,13 @@ func (s *blockState) GetBlock(blkID ids.ID) (*Block, error) {
 		return nil, err
 	}
 
-	// now decode/unmarshal the actual block bytes to block
-	blk := &Block{}
-	if _, err := Codec.Unmarshal(blkw.Blk, blk); err != nil {
+	// now decode/unmarshal the actual block bytes to block and initialize it
+	// with block bytes, status and vm
+	blk, err := parseBlock(blkw.Blk, blkw.Status, s.vm)
+	if err != nil {
 		return nil, err
 	}
 
-	// initialize block with block bytes, status and vm
-	blk.Initialize(blkw.Blk, blkw.Status, s.vm)
-
 	// put block into cache
 	s.blkCache.Put(blkID, blk)
 
@@ -166,3 +222,211 @@ func (s *blockState) SetLastAccepted(lastAccepted ids.ID) error {
 	// persist lastAccepted ID to database with fixed lastAcceptedKey
 	return s.blockDB.Put(lastAcceptedKey, lastAccepted[:])
 }
+
+// GetBlockIDAtHeight returns the ID of the block accepted at [height]
+func (s *blockState) GetBlockIDAtHeight(height uint64) (ids.ID, error) {
+	return database.GetID(s.heightDB, database.PackUInt64(height))
+}
+
+// SetBlockIDAtHeight persists [blkID] as the ID of the block accepted at
+// [height]
+func (s *blockState) SetBlockIDAtHeight(height uint64, blkID ids.ID) error {
+	return database.PutID(s.heightDB, database.PackUInt64(height), blkID)
+}
+
+// GetBlockIDByData returns the ID of the earliest accepted block holding
+// [data]
+func (s *blockState) GetBlockIDByData(data []byte) (ids.ID, error) {
+	return database.GetID(s.dataDB, hashing.ComputeHash256(data))
+}
+
+// GetBlockIDByDataID returns the ID of the earliest accepted block holding
+// the data whose hash is [dataID]
+func (s *blockState) GetBlockIDByDataID(dataID ids.ID) (ids.ID, error) {
+	return database.GetID(s.dataDB, dataID[:])
+}
+
+// IndexData records the accepted block [blk] as holding its data, unless
+// an earlier block holding the same data is recorded already.
+// Data is indexed by its hash, as it can be much longer than a key needs to be.
+func (s *blockState) IndexData(blk *Block) error {
+	for _, data := range blk.Entries() {
+		key := hashing.ComputeHash256(data)
+		indexedID, err := database.GetID(s.dataDB, key)
+		switch err {
+		case nil:
+			// Blocks aren't always indexed in height order, as the blocks
+			// missing after state syncing are fetched from the most recent
+			indexedBlk, err := s.GetBlock(indexedID)
+			if err != nil {
+				return err
+			}
+			if indexedBlk.Height() <= blk.Height() {
+				continue
+			}
+		case database.ErrNotFound:
+		default:
+			return err
+		}
+		if err := database.PutID(s.dataDB, key, blk.ID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// timeKey returns the key of the accepted block with [timestamp] at [height]
+// in timeDB. Keys are ordered by timestamp, then by height.
+func timeKey(timestamp int64, height uint64) []byte {
+	return append(database.PackUInt64(uint64(timestamp)), database.PackUInt64(height)...)
+}
+
+// GetBlockIDsByTime returns the IDs of up to [limit] accepted blocks, in
+// height order, from the block at [fromHeight] with the timestamp
+// [fromTimestamp] on, whose timestamps are before [endTimestamp]
+func (s *blockState) GetBlockIDsByTime(fromTimestamp int64, fromHeight uint64, endTimestamp int64, limit int) ([]ids.ID, error) {
+	it := s.timeDB.NewIteratorWithStart(timeKey(fromTimestamp, fromHeight))
+	defer it.Release()
+
+	var (
+		endKey = timeKey(endTimestamp, 0)
+		blkIDs []ids.ID
+	)
+	for len(blkIDs) < limit && it.Next() {
+		if bytes.Compare(it.Key(), endKey) >= 0 {
+			break
+		}
+		blkID, err := ids.ToID(it.Value())
+		if err != nil {
+			return nil, err
+		}
+		blkIDs = append(blkIDs, blkID)
+	}
+	return blkIDs, it.Error()
+}
+
+// GetBlockIDAfter returns the ID of the earliest accepted block whose
+// timestamp is after [timestamp]
+func (s *blockState) GetBlockIDAfter(timestamp int64) (ids.ID, error) {
+	it := s.timeDB.NewIteratorWithStart(timeKey(timestamp+1, 0))
+	defer it.Release()
+
+	if !it.Next() {
+		if err := it.Error(); err != nil {
+			return ids.Empty, err
+		}
+		return ids.Empty, database.ErrNotFound
+	}
+	return ids.ToID(it.Value())
+}
+
+// IndexTimestamp records the accepted block [blk] by its timestamp
+func (s *blockState) IndexTimestamp(blk *Block) error {
+	return database.PutID(s.timeDB, timeKey(blk.Tmstmp, blk.Height()), blk.ID())
+}
+
+// nonceKeyBytes returns the key of [nonce] of [signer] in nonceDB
+func nonceKeyBytes(signer ids.ShortID, nonce uint64) []byte {
+	return append(signer[:], database.PackUInt64(nonce)...)
+}
+
+// IsNonceUsed returns true if [nonce] of [signer] was used by an accepted
+// block
+func (s *blockState) IsNonceUsed(signer ids.ShortID, nonce uint64) (bool, error) {
+	return s.nonceDB.Has(nonceKeyBytes(signer, nonce))
+}
+
+// IndexNonces records the nonces used by the submitters of the data of the
+// accepted block [blk]
+func (s *blockState) IndexNonces(blk *Block) error {
+	for _, sub := range blk.Submissions() {
+		if !sub.IsSigned() {
+			continue
+		}
+		if err := database.PutID(s.nonceDB, nonceKeyBytes(sub.Signer, sub.Nonce), blk.ID()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rejectedKey returns the key of the rejected block [blkID] at [height] in
+// rejectedDB. Keys are ordered by height.
+func rejectedKey(height uint64, blkID ids.ID) []byte {
+	return append(database.PackUInt64(height), blkID[:]...)
+}
+
+// IndexRejectedBlock records that the rejected block [blk] is to be pruned
+func (s *blockState) IndexRejectedBlock(blk *Block) error {
+	return s.rejectedDB.Put(rejectedKey(blk.Height(), blk.ID()), nil)
+}
+
+// IndexStoredRejectedBlocks goes through every stored block and records
+// the rejected ones as to be pruned
+func (s *blockState) IndexStoredRejectedBlocks() error {
+	it := s.blockDB.NewIterator()
+	defer it.Release()
+
+	for it.Next() {
+		// skip the keys that aren't block IDs, such as lastAcceptedKey
+		if len(it.Key()) != len(ids.Empty) {
+			continue
+		}
+
+		blkw := blkWrapper{}
+		if _, err := Codec.Unmarshal(it.Value(), &blkw); err != nil {
+			return err
+		}
+		if blkw.Status != choices.Rejected {
+			continue
+		}
+		blk, err := parseBlock(blkw.Blk, blkw.Status, s.vm)
+		if err != nil {
+			return err
+		}
+		if err := s.IndexRejectedBlock(blk); err != nil {
+			return err
+		}
+	}
+	return it.Error()
+}
+
+// PruneRejectedBlocks deletes the indexed rejected blocks at heights up to
+// [maxHeight], along with their index entries
+func (s *blockState) PruneRejectedBlocks(maxHeight uint64) error {
+	// collect the keys first, so the database isn't modified while iterating
+	// over it
+	var keys [][]byte
+	it := s.rejectedDB.NewIterator()
+	for it.Next() {
+		key := it.Key()
+		height, err := database.ParseUInt64(key[:database.Uint64Size])
+		if err != nil {
+			it.Release()
+			return err
+		}
+		if height > maxHeight {
+			break
+		}
+		keys = append(keys, append([]byte(nil), key...))
+	}
+	err := it.Error()
+	it.Release()
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		blkID, err := ids.ToID(key[database.Uint64Size:])
+		if err != nil {
+			return err
+		}
+		if err := s.DeleteBlock(blkID); err != nil {
+			return err
+		}
+		if err := s.rejectedDB.Delete(key); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -6,25 +6,123 @@ package timestampvm
 import (
 	"github.com/ava-labs/avalanchego/codec"
 	"github.com/ava-labs/avalanchego/codec/linearcodec"
+	"github.com/ava-labs/avalanchego/codec/reflectcodec"
+	"github.com/ava-labs/avalanchego/utils/wrappers"
 )
 
 const (
 	// CodecVersion is the current default codec version
 	CodecVersion = 0
+
+	// Blocks are marshalled with the codec version of their format, which is
+	// scheduled by the network upgrades.
+	// BlockVersion0 blocks hold a single piece of data.
+	BlockVersion0 = 0
+	// BlockVersion1 blocks hold a list of up to [MaxBlockEntries] pieces of data.
+	BlockVersion1 = 1
+	// BlockVersion2 blocks hold a list of up to [MaxBlockEntries] pieces of
+	// variable length data, each of them at most [MaxDataLen] bytes long.
+	BlockVersion2 = 2
+	// BlockVersion3 blocks hold the same data as [BlockVersion2] blocks, and
+	// commit to the accumulator over the data accepted up to and including
+	// them.
+	BlockVersion3 = 3
+	// BlockVersion4 blocks hold the same data as [BlockVersion3] blocks, along
+	// with the submission of each piece of data, which records who signed it
+	// if it was signed.
+	BlockVersion4 = 4
+	// BlockVersion5 blocks hold the same data as [BlockVersion4] blocks, and
+	// their timestamp has millisecond precision.
+	BlockVersion5 = 5
+
+	// default max length of a slice being marshalled by the codec
+	maxSliceLen = 256 * 1024
+
+	// max size of a response to a blocks request: a batch of blocks, the
+	// codec version, and the length of the batch and of each of its blocks
+	maxBlocksResponseSize = maxSyncBatchBytes + wrappers.ShortLen + (maxSyncBatchSize+1)*wrappers.IntLen
+
+	// max size of a marshalled receipt: its data and blocks, the codec
+	// version, and the length of the data, of the blocks and of each block
+	maxReceiptCodecSize = MaxReceiptSize + wrappers.ShortLen + (MaxReceiptBlocks+2)*wrappers.IntLen
 )
 
 // Codecs do serialization and deserialization
 var (
 	Codec codec.Manager
+
+	// Blocks responses are larger than [Codec] allows
+	syncCodec codec.Manager
+
+	// ReceiptCodec (un)marshals receipts, which are larger than [Codec] allows
+	ReceiptCodec codec.Manager
+
+	// Struct tags of the fields serialized by each block version, in addition
+	// to the fields tagged with [reflectcodec.DefaultTagName]
+	blockVersionTags = map[uint16]string{
+		BlockVersion0: "v0",
+		BlockVersion1: "v1",
+		BlockVersion2: "v2",
+		BlockVersion3: "v3",
+		BlockVersion4: "v4",
+		BlockVersion5: "v5",
+	}
 )
 
+// payloadCodecVersion returns the codec version of the messages describing
+// [blk], so the fields of its block version, such as the milliseconds of its
+// timestamp, are serialized only for the blocks that have them
+func payloadCodecVersion(blk *Block) uint16 {
+	if blk.version >= BlockVersion5 {
+		return BlockVersion5
+	}
+	return CodecVersion
+}
+
 func init() {
-	// Create default codec and manager
-	c := linearcodec.NewDefault()
+	// Create default manager
 	Codec = codec.NewDefaultManager()
 
-	// Register codec to manager with CodecVersion
-	if err := Codec.RegisterCodec(CodecVersion, c); err != nil {
+	for version, tag := range blockVersionTags {
+		// Create a codec serializing the fields common to every version, and
+		// the fields of this block version
+		c := linearcodec.New([]string{reflectcodec.DefaultTagName, tag}, maxSliceLen)
+
+		// Register the app level message types, so they can be unmarshalled
+		// into the Message interface
+		if err := c.RegisterType(&DataGossip{}); err != nil {
+			panic(err)
+		}
+		// Register the app level request types, so they can be unmarshalled
+		// into the Request interface
+		if err := c.RegisterType(&BlocksRequest{}); err != nil {
+			panic(err)
+		}
+		// Registered after the types above, so they keep their IDs
+		if err := c.RegisterType(&SignedDataGossip{}); err != nil {
+			panic(err)
+		}
+		// Register the cross chain request types, so they can be
+		// unmarshalled into the CrossChainRequest interface.
+		// New types must be registered after this one, so the types
+		// registered before keep their IDs.
+		if err := c.RegisterType(&TimestampRequest{}); err != nil {
+			panic(err)
+		}
+
+		// Register codec to manager with the block version
+		if err := Codec.RegisterCodec(version, c); err != nil {
+			panic(err)
+		}
+	}
+
+	syncCodec = codec.NewManager(maxBlocksResponseSize)
+	if err := syncCodec.RegisterCodec(CodecVersion, linearcodec.NewDefault()); err != nil {
+		panic(err)
+	}
+
+	ReceiptCodec = codec.NewManager(maxReceiptCodecSize)
+	if err := ReceiptCodec.RegisterCodec(CodecVersion, linearcodec.NewDefault()); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,181 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/avalanchego/utils/units"
+)
+
+const (
+	DefaultMempoolSize            = 4096
+	DefaultEvictionPolicy         = RejectNew
+	DefaultMaxDataLen             = 4 * units.KiB
+	DefaultBlockCacheSize         = 8192
+	DefaultMaxFutureBlockTime     = time.Hour
+	DefaultLogLevel               = "info"
+	DefaultStateSyncEnabled       = false
+	DefaultStateSummaryFrequency  = 4096
+	DefaultStateSyncMinBlocks     = 4 * DefaultStateSummaryFrequency
+	DefaultMaxMempoolFillRatio    = 0.9
+	DefaultMaxProcessingBlocks    = 256
+	DefaultMaxLastAcceptedAge     = time.Minute
+	DefaultRejectedBlockRetention = 1024
+	DefaultMaxBlocksPerPage       = 256
+	DefaultMaxBatchSize           = 1024
+	DefaultAdminAPIEnabled        = false
+)
+
+var (
+	errInvalidMempoolSize        = errors.New("mempool size must be positive")
+	errInvalidEvictionPolicy     = errors.New("unknown mempool eviction policy")
+	errInvalidMaxDataLen         = fmt.Errorf("max data length must be between 1 and %d", MaxDataLen)
+	errInvalidBlockCacheSize     = errors.New("block cache size must be positive")
+	errInvalidMaxFutureBlockTime = errors.New("max future block time can't be negative")
+	errInvalidSummaryFrequency   = errors.New("state summary frequency must be positive")
+	errInvalidMempoolFillRatio   = errors.New("max mempool fill ratio must be in (0, 1]")
+	errInvalidProcessingBlocks   = errors.New("max processing blocks must be positive")
+	errInvalidLastAcceptedAge    = errors.New("max last accepted age must be positive")
+	errInvalidMaxBlocksPerPage   = errors.New("max blocks per page must be positive")
+	errInvalidMaxBatchSize       = errors.New("max batch size must be positive")
+)
+
+// Config is the chain config of this VM, passed to Initialize as JSON.
+// Fields missing from the JSON keep their default value.
+type Config struct {
+	// Maximum number of pieces of data waiting in the mempool
+	MempoolSize int `json:"mempoolSize"`
+	// What the mempool does with new data once it is full
+	MempoolEvictionPolicy EvictionPolicy `json:"mempoolEvictionPolicy"`
+	// Maximum length of the data accepted in proposals. Can't be more than
+	// [MaxDataLen].
+	MaxDataLen int `json:"maxDataLen"`
+	// Maximum number of blocks held in the block cache
+	BlockCacheSize int `json:"blockCacheSize"`
+	// How far ahead of the local time a block's timestamp can be
+	MaxFutureBlockTime Duration `json:"maxFutureBlockTime"`
+	// Level of the VM's logs
+	LogLevel string `json:"logLevel"`
+	// Whether this node syncs to a state summary of its peers instead of
+	// bootstrapping every block from genesis
+	StateSyncEnabled bool `json:"stateSyncEnabled"`
+	// Number of blocks between the heights state summaries are made at
+	StateSummaryFrequency uint64 `json:"stateSummaryFrequency"`
+	// Minimum number of blocks a state summary must be ahead of the last
+	// accepted block for this node to sync to it
+	StateSyncMinBlocks uint64 `json:"stateSyncMinBlocks"`
+	// Fraction of the mempool that can be full before the VM reports itself
+	// unhealthy
+	MaxMempoolFillRatio float64 `json:"maxMempoolFillRatio"`
+	// Number of verified blocks that can be processing before the VM reports
+	// itself unhealthy
+	MaxProcessingBlocks int `json:"maxProcessingBlocks"`
+	// How old the last accepted block can be, while there is data in the
+	// mempool, before the VM reports itself unhealthy
+	MaxLastAcceptedAge Duration `json:"maxLastAcceptedAge"`
+	// Number of heights a rejected block is kept for, behind the last
+	// accepted block, before it's deleted
+	RejectedBlockRetention uint64 `json:"rejectedBlockRetention"`
+	// Maximum number of blocks returned by a single page of the block
+	// listing APIs
+	MaxBlocksPerPage int `json:"maxBlocksPerPage"`
+	// Maximum number of pieces of data proposed by a single batch proposal
+	MaxBatchSize int `json:"maxBatchSize"`
+	// Whether the admin API, controlling the node at runtime, is served
+	AdminAPIEnabled bool `json:"adminAPIEnabled"`
+}
+
+// DefaultConfig returns the config used when no chain config is given
+func DefaultConfig() Config {
+	return Config{
+		MempoolSize:            DefaultMempoolSize,
+		MempoolEvictionPolicy:  DefaultEvictionPolicy,
+		MaxDataLen:             DefaultMaxDataLen,
+		BlockCacheSize:         DefaultBlockCacheSize,
+		MaxFutureBlockTime:     Duration{DefaultMaxFutureBlockTime},
+		LogLevel:               DefaultLogLevel,
+		StateSyncEnabled:       DefaultStateSyncEnabled,
+		StateSummaryFrequency:  DefaultStateSummaryFrequency,
+		StateSyncMinBlocks:     DefaultStateSyncMinBlocks,
+		MaxMempoolFillRatio:    DefaultMaxMempoolFillRatio,
+		MaxProcessingBlocks:    DefaultMaxProcessingBlocks,
+		MaxLastAcceptedAge:     Duration{DefaultMaxLastAcceptedAge},
+		RejectedBlockRetention: DefaultRejectedBlockRetention,
+		MaxBlocksPerPage:       DefaultMaxBlocksPerPage,
+		MaxBatchSize:           DefaultMaxBatchSize,
+		AdminAPIEnabled:        DefaultAdminAPIEnabled,
+	}
+}
+
+// ParseConfig parses [configBytes] on top of the default config and validates
+// the result. Empty [configBytes] result in the default config.
+func ParseConfig(configBytes []byte) (Config, error) {
+	config := DefaultConfig()
+	if len(configBytes) > 0 {
+		if err := json.Unmarshal(configBytes, &config); err != nil {
+			return Config{}, fmt.Errorf("failed to unmarshal config: %w", err)
+		}
+	}
+	if err := config.Verify(); err != nil {
+		return Config{}, err
+	}
+	return config, nil
+}
+
+// Verify returns nil iff this config is valid
+func (c *Config) Verify() error {
+	switch {
+	case c.MempoolSize <= 0:
+		return errInvalidMempoolSize
+	case c.MempoolEvictionPolicy != RejectNew && c.MempoolEvictionPolicy != DropOldest:
+		return errInvalidEvictionPolicy
+	case c.MaxDataLen <= 0 || c.MaxDataLen > MaxDataLen:
+		return errInvalidMaxDataLen
+	case c.BlockCacheSize <= 0:
+		return errInvalidBlockCacheSize
+	case c.MaxFutureBlockTime.Duration < 0:
+		return errInvalidMaxFutureBlockTime
+	case c.StateSummaryFrequency == 0:
+		return errInvalidSummaryFrequency
+	case c.MaxMempoolFillRatio <= 0 || c.MaxMempoolFillRatio > 1:
+		return errInvalidMempoolFillRatio
+	case c.MaxProcessingBlocks <= 0:
+		return errInvalidProcessingBlocks
+	case c.MaxLastAcceptedAge.Duration <= 0:
+		return errInvalidLastAcceptedAge
+	case c.MaxBlocksPerPage <= 0:
+		return errInvalidMaxBlocksPerPage
+	case c.MaxBatchSize <= 0:
+		return errInvalidMaxBatchSize
+	}
+	_, err := logging.ToLevel(c.LogLevel)
+	return err
+}
+
+// Duration is a time.Duration (un)marshalled as a JSON string such as "1h30m"
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = duration
+	return nil
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseConfig(t *testing.T) {
+	tests := []struct {
+		name           string
+		configBytes    []byte
+		expectedConfig func() Config
+		expectedErr    error
+	}{
+		{
+			name:           "empty",
+			configBytes:    nil,
+			expectedConfig: DefaultConfig,
+		},
+		{
+			name:        "overrides",
+			configBytes: []byte(`{"mempoolSize":10,"mempoolEvictionPolicy":"drop-oldest","maxDataLen":64,"blockCacheSize":16,"maxFutureBlockTime":"30s","logLevel":"debug","stateSyncEnabled":true,"stateSummaryFrequency":128,"stateSyncMinBlocks":256,"maxMempoolFillRatio":0.5,"maxProcessingBlocks":32,"maxLastAcceptedAge":"10s","rejectedBlockRetention":8,"maxBlocksPerPage":32,"maxBatchSize":64,"adminAPIEnabled":true}`),
+			expectedConfig: func() Config {
+				return Config{
+					MempoolSize:            10,
+					MempoolEvictionPolicy:  DropOldest,
+					MaxDataLen:             64,
+					BlockCacheSize:         16,
+					MaxFutureBlockTime:     Duration{30 * time.Second},
+					LogLevel:               "debug",
+					StateSyncEnabled:       true,
+					StateSummaryFrequency:  128,
+					StateSyncMinBlocks:     256,
+					MaxMempoolFillRatio:    0.5,
+					MaxProcessingBlocks:    32,
+					MaxLastAcceptedAge:     Duration{10 * time.Second},
+					RejectedBlockRetention: 8,
+					MaxBlocksPerPage:       32,
+					MaxBatchSize:           64,
+					AdminAPIEnabled:        true,
+				}
+			},
+		},
+		{
+			name:        "partial",
+			configBytes: []byte(`{"mempoolSize":10}`),
+			expectedConfig: func() Config {
+				config := DefaultConfig()
+				config.MempoolSize = 10
+				return config
+			},
+		},
+		{
+			name:        "invalid mempool size",
+			configBytes: []byte(`{"mempoolSize":0}`),
+			expectedErr: errInvalidMempoolSize,
+		},
+		{
+			name:        "invalid eviction policy",
+			configBytes: []byte(`{"mempoolEvictionPolicy":"drop-newest"}`),
+			expectedErr: errInvalidEvictionPolicy,
+		},
+		{
+			name:        "max data length too large",
+			configBytes: []byte(`{"maxDataLen":1000000}`),
+			expectedErr: errInvalidMaxDataLen,
+		},
+		{
+			name:        "negative max future block time",
+			configBytes: []byte(`{"maxFutureBlockTime":"-1s"}`),
+			expectedErr: errInvalidMaxFutureBlockTime,
+		},
+		{
+			name:        "invalid state summary frequency",
+			configBytes: []byte(`{"stateSummaryFrequency":0}`),
+			expectedErr: errInvalidSummaryFrequency,
+		},
+		{
+			name:        "invalid max mempool fill ratio",
+			configBytes: []byte(`{"maxMempoolFillRatio":1.5}`),
+			expectedErr: errInvalidMempoolFillRatio,
+		},
+		{
+			name:        "invalid max processing blocks",
+			configBytes: []byte(`{"maxProcessingBlocks":0}`),
+			expectedErr: errInvalidProcessingBlocks,
+		},
+		{
+			name:        "invalid max last accepted age",
+			configBytes: []byte(`{"maxLastAcceptedAge":"0s"}`),
+			expectedErr: errInvalidLastAcceptedAge,
+		},
+		{
+			name:        "invalid max blocks per page",
+			configBytes: []byte(`{"maxBlocksPerPage":0}`),
+			expectedErr: errInvalidMaxBlocksPerPage,
+		},
+		{
+			name:        "invalid max batch size",
+			configBytes: []byte(`{"maxBatchSize":0}`),
+			expectedErr: errInvalidMaxBatchSize,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require := require.New(t)
+
+			config, err := ParseConfig(test.configBytes)
+			require.ErrorIs(err, test.expectedErr)
+			if test.expectedErr != nil {
+				return
+			}
+			require.Equal(test.expectedConfig(), config)
+		})
+	}
+
+	// unknown log levels are rejected
+	_, err := ParseConfig([]byte(`{"logLevel":"loud"}`))
+	require.Error(t, err)
+}
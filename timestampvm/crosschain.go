@@ -0,0 +1,81 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+)
+
+var _ CrossChainRequestHandler = &crossChainHandler{}
+
+// crossChainHandler answers the requests of the other chains of this node
+type crossChainHandler struct {
+	vm        *VM
+	appSender common.AppSender
+}
+
+func newCrossChainHandler(vm *VM, appSender common.AppSender) *crossChainHandler {
+	return &crossChainHandler{
+		vm:        vm,
+		appSender: appSender,
+	}
+}
+
+// HandleTimestampRequest sends to [chainID] the height and timestamp of the
+// earliest accepted block holding the requested data, if there is one.
+// Errors are never returned, as they would be fatal to the chain: if the
+// block can't be read, the data is reported as not accepted.
+func (h *crossChainHandler) HandleTimestampRequest(ctx context.Context, chainID ids.ID, requestID uint32, req *TimestampRequest) error {
+	var (
+		response     = &TimestampResponse{}
+		codecVersion = uint16(CodecVersion)
+	)
+	blk, err := h.acceptedBlock(req.Data)
+	switch {
+	case err != nil:
+		h.vm.snowCtx.Log.Warn("failed to get timestamp of requested data",
+			zap.Stringer("chainID", chainID),
+			zap.Uint32("requestID", requestID),
+			zap.Error(err),
+		)
+	case blk != nil:
+		response.Accepted = true
+		response.BlkID = blk.ID()
+		response.Height = blk.Height()
+		response.Timestamp = blk.Tmstmp
+		if blk.version >= BlockVersion5 {
+			response.TimestampMs = blk.Timestamp().UnixMilli()
+		}
+		codecVersion = payloadCodecVersion(blk)
+	}
+
+	responseBytes, err := Codec.Marshal(codecVersion, response)
+	if err != nil {
+		h.vm.snowCtx.Log.Warn("failed to build timestamp response", zap.Error(err))
+		return nil
+	}
+	if err := h.appSender.SendCrossChainAppResponse(ctx, chainID, requestID, responseBytes); err != nil {
+		h.vm.snowCtx.Log.Warn("failed to send timestamp response", zap.Error(err))
+	}
+	return nil
+}
+
+// acceptedBlock returns the earliest accepted block holding [data], or nil if
+// there is none
+func (h *crossChainHandler) acceptedBlock(data []byte) (*Block, error) {
+	blkID, err := h.vm.state.GetBlockIDByData(data)
+	if err == database.ErrNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return h.vm.state.GetBlock(blkID)
+}
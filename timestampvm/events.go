@@ -0,0 +1,268 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"go.uber.org/zap"
+
+	"github.com/ava-labs/avalanchego/snow/choices"
+)
+
+const (
+	// EventsEndpoint is the path extension, relative to the chain's API, of
+	// the WebSocket pushing block events
+	EventsEndpoint = "/events"
+
+	// Number of events a subscriber can fall behind before it's dropped
+	eventBufferSize = 1024
+
+	// Time allowed to write an event to a subscriber
+	eventWriteTimeout = 10 * time.Second
+)
+
+var errBadFromHeight = errors.New("fromHeight isn't a height")
+
+var upgrader = websocket.Upgrader{
+	// The chain's API is served to any origin
+	CheckOrigin: func(*http.Request) bool { return true },
+}
+
+// BlockEvent is pushed to the subscribers of the events WebSocket when a
+// block is decided
+type BlockEvent struct {
+	// Accepted or Rejected
+	Status choices.Status `json:"status"`
+	Block  GetBlockReply  `json:"block"`
+}
+
+// subscriber receives the block events published after it subscribed
+type subscriber struct {
+	// Closed when the subscriber falls more than [eventBufferSize] events
+	// behind
+	events chan *BlockEvent
+	// Whether rejected blocks are pushed to the subscriber
+	rejected bool
+}
+
+// eventHub publishes the decided blocks to the subscribers of the events
+// WebSocket
+type eventHub struct {
+	lock        sync.Mutex
+	subscribers map[*subscriber]struct{}
+}
+
+func newEventHub() *eventHub {
+	return &eventHub{
+		subscribers: make(map[*subscriber]struct{}),
+	}
+}
+
+// subscribe returns a new subscriber, receiving rejected blocks if [rejected]
+func (h *eventHub) subscribe(rejected bool) *subscriber {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	sub := &subscriber{
+		events:   make(chan *BlockEvent, eventBufferSize),
+		rejected: rejected,
+	}
+	h.subscribers[sub] = struct{}{}
+	return sub
+}
+
+// unsubscribe stops publishing events to [sub]
+func (h *eventHub) unsubscribe(sub *subscriber) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	delete(h.subscribers, sub)
+}
+
+// publish sends the decision of [blk] to the subscribers. Subscribers that
+// can't keep up are dropped rather than holding up consensus.
+func (h *eventHub) publish(status choices.Status, blk *Block) error {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if len(h.subscribers) == 0 {
+		return nil
+	}
+	event := &BlockEvent{Status: status}
+	if err := fillBlockReply(blk, &event.Block); err != nil {
+		return err
+	}
+	for sub := range h.subscribers {
+		if status == choices.Rejected && !sub.rejected {
+			continue
+		}
+		select {
+		case sub.events <- event:
+		default:
+			delete(h.subscribers, sub)
+			close(sub.events)
+		}
+	}
+	return nil
+}
+
+// eventsHandler serves the WebSocket pushing block events.
+// The query parameter fromHeight, if set, replays the blocks accepted from
+// that height on before the live events, so a subscriber can resume from the
+// last height it received. The query parameter rejected, if true, also pushes
+// the rejected blocks.
+type eventsHandler struct {
+	vm *VM
+}
+
+func newEventsHandler(vm *VM) *eventsHandler {
+	return &eventsHandler{vm: vm}
+}
+
+func (h *eventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	var fromHeight *uint64
+	if s := query.Get("fromHeight"); s != "" {
+		height, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("%s: %q", errBadFromHeight, s), http.StatusBadRequest)
+			return
+		}
+		fromHeight = &height
+	}
+	rejected := false
+	if s := query.Get("rejected"); s != "" {
+		var err error
+		rejected, err = strconv.ParseBool(s)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("rejected isn't a boolean: %q", s), http.StatusBadRequest)
+			return
+		}
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already replied with the error
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe at the last accepted block, so the replay ends where the
+	// live events start
+	h.vm.snowCtx.Lock.RLock()
+	sub := h.vm.events.subscribe(rejected)
+	lastHeight, err := h.lastAcceptedHeight()
+	h.vm.snowCtx.Lock.RUnlock()
+	defer h.vm.events.unsubscribe(sub)
+	if err != nil {
+		h.close(conn, websocket.CloseInternalServerErr, err.Error())
+		return
+	}
+
+	// Handle the control messages of the subscriber, and notice when it
+	// goes away
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.NextReader(); err != nil {
+				return
+			}
+		}
+	}()
+
+	if fromHeight != nil {
+		for height := *fromHeight; height <= lastHeight; height++ {
+			event, err := h.acceptedEvent(height)
+			if err != nil {
+				h.close(conn, websocket.CloseInternalServerErr, err.Error())
+				return
+			}
+			if err := h.write(conn, event); err != nil {
+				return
+			}
+			select {
+			case <-closed:
+				return
+			default:
+			}
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-sub.events:
+			if !ok {
+				h.close(conn, websocket.CloseTryAgainLater, "subscriber fell behind")
+				return
+			}
+			if err := h.write(conn, event); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// lastAcceptedHeight returns the height of the last accepted block.
+// Assumes the context lock is held.
+func (h *eventsHandler) lastAcceptedHeight() (uint64, error) {
+	blkID, err := h.vm.state.GetLastAccepted()
+	if err != nil {
+		return 0, err
+	}
+	blk, err := h.vm.state.GetBlock(blkID)
+	if err != nil {
+		return 0, err
+	}
+	return blk.Height(), nil
+}
+
+// acceptedEvent returns the event of the block accepted at [height]
+func (h *eventsHandler) acceptedEvent(height uint64) (*BlockEvent, error) {
+	h.vm.snowCtx.Lock.RLock()
+	defer h.vm.snowCtx.Lock.RUnlock()
+
+	blkID, err := h.vm.state.GetBlockIDAtHeight(height)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get block at height %d: %w", height, err)
+	}
+	blk, err := h.vm.state.GetBlock(blkID)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get block %s: %w", blkID, err)
+	}
+	event := &BlockEvent{Status: choices.Accepted}
+	if err := fillBlockReply(blk, &event.Block); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// write pushes [event] to the subscriber of [conn]
+func (h *eventsHandler) write(conn *websocket.Conn, event *BlockEvent) error {
+	if err := conn.SetWriteDeadline(time.Now().Add(eventWriteTimeout)); err != nil {
+		return err
+	}
+	if err := conn.WriteJSON(event); err != nil {
+		h.vm.snowCtx.Log.Debug("failed to push block event", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// close ends the subscription of [conn] with [code] and [reason]
+func (h *eventsHandler) close(conn *websocket.Conn, code int, reason string) {
+	msg := websocket.FormatCloseMessage(code, reason)
+	if err := conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(eventWriteTimeout)); err != nil {
+		h.vm.snowCtx.Log.Debug("failed to close block events", zap.Error(err))
+	}
+}
@@ -0,0 +1,242 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/time/rate"
+
+	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/utils/units"
+)
+
+const (
+	// maximum number of recently gossiped data IDs to remember
+	gossipCacheSize = 16384
+	// maximum number of data items sent in a single gossip message
+	maxGossipBatchSize = 512
+	// maximum total length of the data sent in a single gossip message
+	maxGossipBatchBytes = 128 * units.KiB
+	// how often pending gossip is flushed if it couldn't be sent right away
+	gossipFrequency = 250 * time.Millisecond
+	// outbound gossip messages allowed per second, and the allowed burst
+	gossipRate  = 20
+	gossipBurst = 10
+	// inbound gossip messages allowed per second from a single peer, and the
+	// allowed burst
+	peerGossipRate  = 20
+	peerGossipBurst = 10
+)
+
+var _ MessageHandler = &gossiper{}
+
+// gossiper propagates proposed data to the other nodes of the network and
+// handles the data gossiped to this node.
+type gossiper struct {
+	vm        *VM
+	appSender common.AppSender
+
+	// IDs of the data recently gossiped or received through gossip.
+	// Used to avoid gossiping the same data more than once.
+	seen cache.Cacher[ids.ID, struct{}]
+
+	// data waiting to be gossiped
+	pending []gossipItem
+
+	// limits the outbound gossip messages of this node
+	outboundLimiter *rate.Limiter
+	// limits the inbound gossip messages per peer
+	inboundLimiters map[ids.NodeID]*rate.Limiter
+
+	shutdownChan chan struct{}
+}
+
+// gossipItem is a piece of data waiting to be gossiped, with its submission
+// if it's signed
+type gossipItem struct {
+	data []byte
+	sub  *Submission
+}
+
+func newGossiper(vm *VM, appSender common.AppSender) *gossiper {
+	return &gossiper{
+		vm:              vm,
+		appSender:       appSender,
+		seen:            &cache.LRU[ids.ID, struct{}]{Size: gossipCacheSize},
+		outboundLimiter: rate.NewLimiter(gossipRate, gossipBurst),
+		inboundLimiters: make(map[ids.NodeID]*rate.Limiter),
+		shutdownChan:    make(chan struct{}),
+	}
+}
+
+// start periodically flushes the pending gossip until [shutdown] is called.
+func (g *gossiper) start() {
+	ticker := time.NewTicker(gossipFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			g.vm.snowCtx.Lock.Lock()
+			g.flush(context.TODO())
+			g.vm.snowCtx.Lock.Unlock()
+		case <-g.shutdownChan:
+			return
+		}
+	}
+}
+
+// shutdown stops the gossip loop
+func (g *gossiper) shutdown() {
+	close(g.shutdownChan)
+}
+
+// add queues [data] to be gossiped to the network along with [sub], its
+// submission if it's signed, unless it was gossiped recently, and flushes the
+// pending gossip
+func (g *gossiper) add(ctx context.Context, data []byte, sub *Submission) {
+	g.queue(data, sub)
+	g.flush(ctx)
+}
+
+// queue queues [data] to be gossiped along with [sub] by the next flush,
+// unless it was gossiped recently
+func (g *gossiper) queue(data []byte, sub *Submission) {
+	id := dataID(data)
+	if _, seen := g.seen.Get(id); seen {
+		return
+	}
+	g.seen.Put(id, struct{}{})
+	g.pending = append(g.pending, gossipItem{data: data, sub: sub})
+}
+
+// flush sends the pending data to the network in batches of at most
+// [maxGossipBatchSize] pieces and [maxGossipBatchBytes] bytes of data, as long
+// as the outbound rate limit allows it.
+// Whatever is left is sent by a later flush.
+func (g *gossiper) flush(ctx context.Context) {
+	for len(g.pending) > 0 && g.outboundLimiter.Allow() {
+		// A batch always holds at least one piece of data
+		batchSize, batchBytes := 1, len(g.pending[0].data)
+		for batchSize < len(g.pending) && batchSize < maxGossipBatchSize {
+			batchBytes += len(g.pending[batchSize].data)
+			if batchBytes > maxGossipBatchBytes {
+				break
+			}
+			batchSize++
+		}
+
+		msgBytes, err := BuildMessage(gossipMessage(g.pending[:batchSize]))
+		if err != nil {
+			g.vm.snowCtx.Log.Warn("failed to build gossip message", zap.Error(err))
+			return
+		}
+		if err := g.appSender.SendAppGossip(ctx, msgBytes); err != nil {
+			g.vm.snowCtx.Log.Warn("failed to send gossip message", zap.Error(err))
+			return
+		}
+		g.pending = g.pending[batchSize:]
+	}
+}
+
+// gossipMessage returns the message gossiping [items]. Data is gossiped
+// without submissions, as nodes that don't know signed data expect, unless
+// some of it is signed.
+func gossipMessage(items []gossipItem) Message {
+	var (
+		data   = make([][]byte, len(items))
+		signed = false
+	)
+	for i, item := range items {
+		data[i] = item.data
+		signed = signed || item.sub != nil
+	}
+	if !signed {
+		return &DataGossip{Data: data}
+	}
+
+	sbmsns := make([]Submission, len(items))
+	for i, item := range items {
+		if item.sub != nil {
+			sbmsns[i] = *item.sub
+		}
+	}
+	return &SignedDataGossip{Data: data, Sbmsns: sbmsns}
+}
+
+// HandleDataGossip adds the data gossiped by [nodeID] to the mempool and
+// gossips the data this node hadn't seen before.
+func (g *gossiper) HandleDataGossip(nodeID ids.NodeID, msg *DataGossip) error {
+	if !g.allow(nodeID) {
+		return nil
+	}
+	for _, data := range msg.Data {
+		g.propose(nodeID, data, nil)
+	}
+	return nil
+}
+
+// HandleSignedDataGossip adds the data gossiped by [nodeID] to the mempool
+// along with its submission, and gossips the data this node hadn't seen
+// before.
+func (g *gossiper) HandleSignedDataGossip(nodeID ids.NodeID, msg *SignedDataGossip) error {
+	if !g.allow(nodeID) {
+		return nil
+	}
+	if len(msg.Sbmsns) != len(msg.Data) {
+		g.vm.snowCtx.Log.Debug("dropping malformed gossip",
+			zap.Stringer("nodeID", nodeID),
+			zap.Int("numData", len(msg.Data)),
+			zap.Int("numSubmissions", len(msg.Sbmsns)),
+		)
+		return nil
+	}
+	for i, data := range msg.Data {
+		var sub *Submission
+		if msg.Sbmsns[i].IsSigned() {
+			sub = &msg.Sbmsns[i]
+		}
+		g.propose(nodeID, data, sub)
+	}
+	return nil
+}
+
+// allow returns true if the rate limit of [nodeID] allows handling its gossip
+func (g *gossiper) allow(nodeID ids.NodeID) bool {
+	limiter, ok := g.inboundLimiters[nodeID]
+	if !ok {
+		limiter = rate.NewLimiter(peerGossipRate, peerGossipBurst)
+		g.inboundLimiters[nodeID] = limiter
+	}
+	if !limiter.Allow() {
+		g.vm.snowCtx.Log.Debug("dropping rate limited gossip",
+			zap.Stringer("nodeID", nodeID),
+		)
+		return false
+	}
+	return true
+}
+
+// propose proposes [data] gossiped by [nodeID], unless it was seen recently
+func (g *gossiper) propose(nodeID ids.NodeID, data []byte, sub *Submission) {
+	if _, seen := g.seen.Get(dataID(data)); seen {
+		return
+	}
+	if err := g.vm.proposeSignedBlock(data, sub); err != nil {
+		g.vm.snowCtx.Log.Debug("dropping gossiped data",
+			zap.Stringer("nodeID", nodeID),
+			zap.Error(err),
+		)
+	}
+}
+
+// disconnected forgets the rate limiter of [nodeID]
+func (g *gossiper) disconnected(nodeID ids.NodeID) {
+	delete(g.inboundLimiters, nodeID)
+}
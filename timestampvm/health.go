@@ -0,0 +1,93 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	errUnhealthy       = errors.New("timestampvm is unhealthy")
+	errNotBootstrapped = errors.New("not bootstrapped")
+)
+
+// Health is the health report of the VM, returned by HealthCheck
+type Health struct {
+	Bootstrapped       bool     `json:"bootstrapped"`
+	MempoolSize        int      `json:"mempoolSize"`
+	MempoolFillRatio   float64  `json:"mempoolFillRatio"`
+	ProcessingBlocks   int      `json:"processingBlocks"`
+	LastAcceptedHeight uint64   `json:"lastAcceptedHeight"`
+	LastAcceptedAge    Duration `json:"lastAcceptedAge"`
+	Database           string   `json:"database"`
+}
+
+// HealthCheck implements the common.VM interface.
+// Returns an error, along with the report, if the VM isn't bootstrapped, its
+// database isn't reachable, or any of the health thresholds in the config is
+// breached.
+func (vm *VM) HealthCheck(ctx context.Context) (interface{}, error) {
+	var (
+		health = &Health{
+			Bootstrapped:     vm.bootstrapped.Get(),
+			MempoolSize:      vm.mempool.Len(),
+			MempoolFillRatio: float64(vm.mempool.Len()) / float64(vm.mempool.MaxSize()),
+			ProcessingBlocks: len(vm.verifiedBlocks),
+			Database:         "healthy",
+		}
+		errs []error
+	)
+	if !health.Bootstrapped {
+		errs = append(errs, errNotBootstrapped)
+	}
+	if health.MempoolFillRatio > vm.config.MaxMempoolFillRatio {
+		errs = append(errs, fmt.Errorf("mempool is %.2f full, more than %.2f", health.MempoolFillRatio, vm.config.MaxMempoolFillRatio))
+	}
+	if health.ProcessingBlocks > vm.config.MaxProcessingBlocks {
+		errs = append(errs, fmt.Errorf("%d blocks are processing, more than %d", health.ProcessingBlocks, vm.config.MaxProcessingBlocks))
+	}
+
+	if _, err := vm.state.HealthCheck(ctx); err != nil {
+		health.Database = err.Error()
+		errs = append(errs, fmt.Errorf("database is unreachable: %w", err))
+	} else if err := vm.checkLastAccepted(health); err != nil {
+		errs = append(errs, err)
+	}
+
+	if len(errs) == 0 {
+		return health, nil
+	}
+	reasons := make([]string, len(errs))
+	for i, err := range errs {
+		reasons[i] = err.Error()
+	}
+	return health, fmt.Errorf("%w: %s", errUnhealthy, strings.Join(reasons, "; "))
+}
+
+// checkLastAccepted fills out [health] with the last accepted block, and
+// returns an error if the mempool holds data while the last accepted block is
+// older than the configured max last accepted age
+func (vm *VM) checkLastAccepted(health *Health) error {
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return fmt.Errorf("couldn't get last accepted block ID: %w", err)
+	}
+	lastAcceptedBlock, err := vm.getBlock(lastAccepted)
+	if err != nil {
+		return fmt.Errorf("couldn't get last accepted block: %w", err)
+	}
+	health.LastAcceptedHeight = lastAcceptedBlock.Height()
+	health.LastAcceptedAge = Duration{time.Since(lastAcceptedBlock.Timestamp()).Truncate(time.Second)}
+
+	// An old last accepted block only matters if there is data to put into
+	// a new block
+	if health.MempoolSize > 0 && health.LastAcceptedAge.Duration > vm.config.MaxLastAcceptedAge.Duration {
+		return fmt.Errorf("last accepted block is %s old while %d pieces of data are pending, more than %s", health.LastAcceptedAge, health.MempoolSize, vm.config.MaxLastAcceptedAge)
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/linkedhashmap"
+)
+
+// EvictionPolicy defines what the mempool does with new data once it is full
+type EvictionPolicy byte
+
+const (
+	// RejectNew rejects new data while the mempool is full
+	RejectNew EvictionPolicy = iota
+	// DropOldest drops the oldest data in the mempool to make room for new data
+	DropOldest
+)
+
+var (
+	errMempoolFull   = errors.New("mempool is full")
+	errDuplicateData = errors.New("data is already in the mempool")
+
+	evictionPolicyNames = map[EvictionPolicy]string{
+		RejectNew:  "reject-new",
+		DropOldest: "drop-oldest",
+	}
+)
+
+func (p EvictionPolicy) String() string {
+	if name, ok := evictionPolicyNames[p]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+// MarshalText marshals [p] to its name
+func (p EvictionPolicy) MarshalText() ([]byte, error) {
+	if _, ok := evictionPolicyNames[p]; !ok {
+		return nil, fmt.Errorf("%w: %d", errInvalidEvictionPolicy, p)
+	}
+	return []byte(p.String()), nil
+}
+
+// UnmarshalText sets [p] to the policy named [text]
+func (p *EvictionPolicy) UnmarshalText(text []byte) error {
+	for policy, name := range evictionPolicyNames {
+		if name == string(text) {
+			*p = policy
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: %q", errInvalidEvictionPolicy, text)
+}
+
+// Mempool holds proposed data that hasn't been put into a block yet, along
+// with the submission of the signed data.
+// Data is kept in the order it was added and each piece of data is held at
+// most once.
+// Mempool is not safe for concurrent use; the VM accesses it with the
+// context lock held.
+type Mempool struct {
+	maxSize int
+	policy  EvictionPolicy
+
+	// data ID --> data, in insertion order
+	data linkedhashmap.LinkedHashmap[ids.ID, []byte]
+	// data ID --> submission of the data, for the signed data
+	submissions map[ids.ID]*Submission
+}
+
+// NewMempool returns an empty mempool holding at most [maxSize] pieces of
+// data and handling new data according to [policy] once full
+func NewMempool(maxSize int, policy EvictionPolicy) *Mempool {
+	return &Mempool{
+		maxSize:     maxSize,
+		policy:      policy,
+		data:        linkedhashmap.New[ids.ID, []byte](),
+		submissions: make(map[ids.ID]*Submission),
+	}
+}
+
+// Add adds [data] to the mempool.
+// Returns an error if [data] is already in the mempool, or if the mempool is
+// full and its policy is [RejectNew].
+func (m *Mempool) Add(data []byte) error {
+	return m.AddSigned(data, nil)
+}
+
+// AddSigned adds [data], submitted with [sub], to the mempool. [sub] is nil
+// for unsigned data.
+// Returns an error if [data] is already in the mempool, or if the mempool is
+// full and its policy is [RejectNew].
+func (m *Mempool) AddSigned(data []byte, sub *Submission) error {
+	id := dataID(data)
+	if _, ok := m.data.Get(id); ok {
+		return errDuplicateData
+	}
+
+	if m.data.Len() >= m.maxSize {
+		if m.policy != DropOldest {
+			return errMempoolFull
+		}
+		oldestID, _, _ := m.data.Oldest()
+		m.delete(oldestID)
+	}
+
+	m.data.Put(id, data)
+	if sub != nil {
+		m.submissions[id] = sub
+	}
+	return nil
+}
+
+// Has returns true if [data] is in the mempool
+func (m *Mempool) Has(data []byte) bool {
+	_, ok := m.data.Get(dataID(data))
+	return ok
+}
+
+// HasID returns true if the data whose ID is [id] is in the mempool
+func (m *Mempool) HasID(id ids.ID) bool {
+	_, ok := m.data.Get(id)
+	return ok
+}
+
+// Remove removes [data] from the mempool, if it's there
+func (m *Mempool) Remove(data []byte) {
+	m.delete(dataID(data))
+}
+
+// Submission returns the submission of [data], or nil if [data] isn't signed
+// or isn't in the mempool
+func (m *Mempool) Submission(data []byte) *Submission {
+	return m.submissions[dataID(data)]
+}
+
+// Peek returns the oldest data in the mempool without removing it.
+// Returns false if the mempool is empty.
+func (m *Mempool) Peek() ([]byte, bool) {
+	_, data, ok := m.data.Oldest()
+	return data, ok
+}
+
+// Pop removes and returns the oldest data in the mempool.
+// Returns false if the mempool is empty.
+func (m *Mempool) Pop() ([]byte, bool) {
+	id, data, ok := m.data.Oldest()
+	if ok {
+		m.delete(id)
+	}
+	return data, ok
+}
+
+// delete removes the data [id] and its submission from the mempool
+func (m *Mempool) delete(id ids.ID) {
+	m.data.Delete(id)
+	delete(m.submissions, id)
+}
+
+// Len returns the number of pieces of data in the mempool
+func (m *Mempool) Len() int {
+	return m.data.Len()
+}
+
+// MaxSize returns the maximum number of pieces of data in the mempool
+func (m *Mempool) MaxSize() int {
+	return m.maxSize
+}
+
+// Contents returns the data in the mempool, oldest first
+func (m *Mempool) Contents() [][]byte {
+	contents := make([][]byte, 0, m.data.Len())
+	it := m.data.NewIterator()
+	for it.Next() {
+		contents = append(contents, it.Value())
+	}
+	return contents
+}
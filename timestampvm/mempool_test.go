@@ -0,0 +1,49 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMempoolRejectNew(t *testing.T) {
+	require := require.New(t)
+
+	mempool := NewMempool(2, RejectNew)
+	require.NoError(mempool.Add([]byte{1}))
+	require.ErrorIs(mempool.Add([]byte{1}), errDuplicateData)
+	require.NoError(mempool.Add([]byte{2}))
+	require.ErrorIs(mempool.Add([]byte{3}), errMempoolFull)
+	require.Equal([][]byte{{1}, {2}}, mempool.Contents())
+
+	// removing data makes room for new data
+	mempool.Remove([]byte{1})
+	require.False(mempool.Has([]byte{1}))
+	require.NoError(mempool.Add([]byte{3}))
+	require.Equal([][]byte{{2}, {3}}, mempool.Contents())
+
+	data, ok := mempool.Pop()
+	require.True(ok)
+	require.Equal([]byte{2}, data)
+	require.Equal(1, mempool.Len())
+}
+
+func TestMempoolDropOldest(t *testing.T) {
+	require := require.New(t)
+
+	mempool := NewMempool(2, DropOldest)
+	require.NoError(mempool.Add([]byte{1}))
+	require.NoError(mempool.Add([]byte{2}))
+	require.NoError(mempool.Add([]byte{3}))
+	require.Equal([][]byte{{2}, {3}}, mempool.Contents())
+
+	_, ok := mempool.Pop()
+	require.True(ok)
+	_, ok = mempool.Pop()
+	require.True(ok)
+	_, ok = mempool.Pop()
+	require.False(ok)
+}
@@ -0,0 +1,165 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+
+	"github.com/ava-labs/avalanchego/ids"
+)
+
+var (
+	_ Message = &DataGossip{}
+	_ Message = &SignedDataGossip{}
+	_ Request = &BlocksRequest{}
+
+	_ CrossChainRequest = &TimestampRequest{}
+)
+
+// Message is an application level message exchanged between timestampvm
+// nodes over the AppSender.
+type Message interface {
+	// Handle passes this message to the matching method of [handler]
+	Handle(handler MessageHandler, nodeID ids.NodeID) error
+}
+
+// MessageHandler handles the messages received from other nodes
+type MessageHandler interface {
+	HandleDataGossip(nodeID ids.NodeID, msg *DataGossip) error
+	HandleSignedDataGossip(nodeID ids.NodeID, msg *SignedDataGossip) error
+}
+
+// DataGossip carries proposed data that hasn't been put into a block yet
+type DataGossip struct {
+	Data [][]byte `serialize:"true"`
+}
+
+// Handle implements the Message interface
+func (msg *DataGossip) Handle(handler MessageHandler, nodeID ids.NodeID) error {
+	return handler.HandleDataGossip(nodeID, msg)
+}
+
+// SignedDataGossip carries proposed data that hasn't been put into a block
+// yet, along with the submission of each piece of data. The submission of
+// unsigned data is empty.
+type SignedDataGossip struct {
+	Data   [][]byte     `serialize:"true"`
+	Sbmsns []Submission `serialize:"true"`
+}
+
+// Handle implements the Message interface
+func (msg *SignedDataGossip) Handle(handler MessageHandler, nodeID ids.NodeID) error {
+	return handler.HandleSignedDataGossip(nodeID, msg)
+}
+
+// Request is an application level request sent by a timestampvm node to
+// another one over the AppSender.
+type Request interface {
+	// Handle passes this request to the matching method of [handler]
+	Handle(ctx context.Context, handler RequestHandler, nodeID ids.NodeID, requestID uint32) error
+}
+
+// RequestHandler handles the requests received from other nodes
+type RequestHandler interface {
+	HandleBlocksRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, req *BlocksRequest) error
+}
+
+// BlocksRequest asks for an accepted block and its ancestors
+type BlocksRequest struct {
+	// ID of the most recent block requested
+	BlkID ids.ID `serialize:"true"`
+	// Maximum number of blocks requested
+	MaxBlocks uint32 `serialize:"true"`
+}
+
+// Handle implements the Request interface
+func (req *BlocksRequest) Handle(ctx context.Context, handler RequestHandler, nodeID ids.NodeID, requestID uint32) error {
+	return handler.HandleBlocksRequest(ctx, nodeID, requestID, req)
+}
+
+// BlocksResponse is the reply to a BlocksRequest
+type BlocksResponse struct {
+	// Bytes of the requested block followed by its ancestors, from the most
+	// recent to the oldest. Empty if the requested block isn't accepted.
+	Blks [][]byte `serialize:"true"`
+}
+
+// CrossChainRequest is an application level request sent to a timestampvm
+// chain by another chain of the same node.
+type CrossChainRequest interface {
+	// Handle passes this request to the matching method of [handler]
+	Handle(ctx context.Context, handler CrossChainRequestHandler, chainID ids.ID, requestID uint32) error
+}
+
+// CrossChainRequestHandler handles the requests received from other chains
+type CrossChainRequestHandler interface {
+	HandleTimestampRequest(ctx context.Context, chainID ids.ID, requestID uint32, req *TimestampRequest) error
+}
+
+// TimestampRequest asks whether a piece of data was accepted, and when
+type TimestampRequest struct {
+	Data []byte `serialize:"true"`
+}
+
+// Handle implements the CrossChainRequest interface
+func (req *TimestampRequest) Handle(ctx context.Context, handler CrossChainRequestHandler, chainID ids.ID, requestID uint32) error {
+	return handler.HandleTimestampRequest(ctx, chainID, requestID, req)
+}
+
+// TimestampResponse is the reply to a TimestampRequest
+type TimestampResponse struct {
+	// True if the data was accepted. The other fields are empty otherwise.
+	Accepted bool `serialize:"true"`
+	// ID of the earliest accepted block holding the data
+	BlkID ids.ID `serialize:"true"`
+	// Height of that block
+	Height uint64 `serialize:"true"`
+	// Timestamp of that block, in Unix seconds
+	Timestamp int64 `serialize:"true"`
+	// Timestamp of that block, in Unix milliseconds. Zero for blocks before
+	// [BlockVersion5], whose responses are marshalled without it.
+	TimestampMs int64 `v5:"true"`
+}
+
+// ParseMessage parses [bytes] into a Message
+func ParseMessage(bytes []byte) (Message, error) {
+	var msg Message
+	if _, err := Codec.Unmarshal(bytes, &msg); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// BuildMessage returns the byte representation of [msg]
+func BuildMessage(msg Message) ([]byte, error) {
+	return Codec.Marshal(CodecVersion, &msg)
+}
+
+// ParseRequest parses [bytes] into a Request
+func ParseRequest(bytes []byte) (Request, error) {
+	var req Request
+	if _, err := Codec.Unmarshal(bytes, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// BuildRequest returns the byte representation of [req]
+func BuildRequest(req Request) ([]byte, error) {
+	return Codec.Marshal(CodecVersion, &req)
+}
+
+// ParseCrossChainRequest parses [bytes] into a CrossChainRequest
+func ParseCrossChainRequest(bytes []byte) (CrossChainRequest, error) {
+	var req CrossChainRequest
+	if _, err := Codec.Unmarshal(bytes, &req); err != nil {
+		return nil, err
+	}
+	return req, nil
+}
+
+// BuildCrossChainRequest returns the byte representation of [req]
+func BuildCrossChainRequest(req CrossChainRequest) ([]byte, error) {
+	return Codec.Marshal(CodecVersion, &req)
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"strings"
+	"time"
+
+	"github.com/gorilla/rpc/v2"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ava-labs/avalanchego/utils/wrappers"
+)
+
+// Causes of dropped proposals, as labelled in the metrics
+const (
+	dropCauseEvicted     = "evicted"
+	dropCauseMempoolFull = "mempool_full"
+	dropCauseInvalid     = "invalid"
+	dropCauseBuildFailed = "build_failed"
+	dropCauseRejected    = "rejected"
+	dropCauseCleared     = "cleared"
+)
+
+// metrics of this VM, served by the node along with its own metrics
+type metrics struct {
+	blocksBuilt    prometheus.Counter
+	blocksVerified prometheus.Counter
+	blocksAccepted prometheus.Counter
+	blocksRejected prometheus.Counter
+	verifyFailures prometheus.Counter
+
+	verifyDuration prometheus.Histogram
+	acceptDuration prometheus.Histogram
+
+	// cause --> number of proposals dropped for it
+	proposalsDropped *prometheus.CounterVec
+
+	blockCacheHits   prometheus.Counter
+	blockCacheMisses prometheus.Counter
+
+	// API method --> number of calls to it, and of calls failing
+	apiCalls  *prometheus.CounterVec
+	apiErrors *prometheus.CounterVec
+}
+
+// newMetrics registers the metrics of [vm] with [registerer]
+func newMetrics(vm *VM, registerer prometheus.Registerer) (*metrics, error) {
+	m := &metrics{
+		blocksBuilt: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blocks_built",
+			Help: "Number of blocks built by this node",
+		}),
+		blocksVerified: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blocks_verified",
+			Help: "Number of blocks that passed verification",
+		}),
+		blocksAccepted: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blocks_accepted",
+			Help: "Number of blocks accepted",
+		}),
+		blocksRejected: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "blocks_rejected",
+			Help: "Number of blocks rejected",
+		}),
+		verifyFailures: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "block_verification_failures",
+			Help: "Number of blocks that failed verification",
+		}),
+		verifyDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "block_verify_duration_seconds",
+			Help:    "Time spent verifying a block",
+			Buckets: prometheus.DefBuckets,
+		}),
+		acceptDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "block_accept_duration_seconds",
+			Help:    "Time spent accepting a block",
+			Buckets: prometheus.DefBuckets,
+		}),
+		proposalsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "proposals_dropped",
+			Help: "Number of proposals dropped without being accepted, by cause",
+		}, []string{"cause"}),
+		blockCacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "block_cache_hits",
+			Help: "Number of blocks found in the block cache",
+		}),
+		blockCacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "block_cache_misses",
+			Help: "Number of blocks looked up in the block cache and not found",
+		}),
+		apiCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_calls",
+			Help: "Number of API calls, by method",
+		}, []string{"method"}),
+		apiErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "api_errors",
+			Help: "Number of API calls that failed, by method",
+		}, []string{"method"}),
+	}
+	mempoolSize := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "mempool_size",
+		Help: "Number of pieces of data in the mempool",
+	}, func() float64 {
+		return float64(vm.mempool.Len())
+	})
+
+	errs := wrappers.Errs{}
+	errs.Add(
+		registerer.Register(m.blocksBuilt),
+		registerer.Register(m.blocksVerified),
+		registerer.Register(m.blocksAccepted),
+		registerer.Register(m.blocksRejected),
+		registerer.Register(m.verifyFailures),
+		registerer.Register(m.verifyDuration),
+		registerer.Register(m.acceptDuration),
+		registerer.Register(m.proposalsDropped),
+		registerer.Register(m.blockCacheHits),
+		registerer.Register(m.blockCacheMisses),
+		registerer.Register(m.apiCalls),
+		registerer.Register(m.apiErrors),
+		registerer.Register(mempoolSize),
+	)
+	return m, errs.Err
+}
+
+// observeVerify records the verification of a block started at [start],
+// which failed if [err] isn't nil
+func (m *metrics) observeVerify(start time.Time, err error) {
+	m.verifyDuration.Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.verifyFailures.Inc()
+		return
+	}
+	m.blocksVerified.Inc()
+}
+
+// observeAPICall records the call to a JSON-RPC method described by [info]
+func (m *metrics) observeAPICall(info *rpc.RequestInfo) {
+	m.observeAPIMethod(strings.ToLower(info.Method), info.Error != nil)
+}
+
+// observeAPIMethod records a call to [method], which [failed] or not
+func (m *metrics) observeAPIMethod(method string, failed bool) {
+	m.apiCalls.WithLabelValues(method).Inc()
+	if failed {
+		m.apiErrors.WithLabelValues(method).Inc()
+	}
+}
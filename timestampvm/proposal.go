@@ -0,0 +1,143 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/linkedhashmap"
+)
+
+// Number of dropped proposals whose reason is remembered
+const maxDroppedProposals = 4096
+
+// ProposalStatus is the status of proposed data, as seen by this node
+type ProposalStatus string
+
+const (
+	// ProposalUnknown is the status of data this node never heard of, or
+	// dropped too long ago to remember why
+	ProposalUnknown ProposalStatus = "Unknown"
+	// ProposalPending is the status of data waiting in the mempool to be put
+	// into a block
+	ProposalPending ProposalStatus = "Pending"
+	// ProposalProcessing is the status of data in a block that is neither
+	// accepted nor rejected yet
+	ProposalProcessing ProposalStatus = "Processing"
+	// ProposalAccepted is the status of data in an accepted block
+	ProposalAccepted ProposalStatus = "Accepted"
+	// ProposalDropped is the status of data that left the mempool without
+	// being accepted. It has to be proposed again to be timestamped.
+	ProposalDropped ProposalStatus = "Dropped"
+)
+
+// ProposalResult is the outcome of a proposal of a batch
+type ProposalResult string
+
+const (
+	// ProposalQueued is the result of data added to the mempool
+	ProposalQueued ProposalResult = "Queued"
+	// ProposalDuplicate is the result of data already in the mempool
+	ProposalDuplicate ProposalResult = "Duplicate"
+	// ProposalAlreadyAccepted is the result of data already accepted in a
+	// block. Its status tells which one.
+	ProposalAlreadyAccepted ProposalResult = "AlreadyAccepted"
+	// ProposalInvalid is the result of data that can't be put into a block
+	ProposalInvalid ProposalResult = "Invalid"
+	// ProposalMempoolFull is the result of data refused by the full mempool.
+	// It can be proposed again later.
+	ProposalMempoolFull ProposalResult = "MempoolFull"
+)
+
+// ProposalID returns the ID of the proposal of [data]. The same data has the
+// same ID on every node, whether it's proposed to the node or gossiped to it.
+func ProposalID(data []byte) ids.ID {
+	return dataID(data)
+}
+
+// proposalStatus is the status of a proposal
+type proposalStatus struct {
+	status ProposalStatus
+	// Block holding the data, if it's processing or accepted
+	blk *Block
+	// Why the data was dropped, if it was
+	reason string
+}
+
+// getProposalStatus returns the status of the proposal [proposalID].
+// Data accepted in a block wins over data still processing in a sibling
+// block, which wins over a copy of the data pending in the mempool.
+func (vm *VM) getProposalStatus(proposalID ids.ID) (*proposalStatus, error) {
+	blkID, err := vm.state.GetBlockIDByDataID(proposalID)
+	switch err {
+	case nil:
+		blk, err := vm.state.GetBlock(blkID)
+		if err != nil {
+			return nil, err
+		}
+		return &proposalStatus{status: ProposalAccepted, blk: blk}, nil
+	case database.ErrNotFound:
+	default:
+		return nil, err
+	}
+
+	if blkIDs, ok := vm.verifiedData[proposalID]; ok {
+		blkID, _ := blkIDs.Peek()
+		return &proposalStatus{status: ProposalProcessing, blk: vm.verifiedBlocks[blkID]}, nil
+	}
+	if vm.mempool.HasID(proposalID) {
+		return &proposalStatus{status: ProposalPending}, nil
+	}
+	if reason, ok := vm.dropped.reason(proposalID); ok {
+		return &proposalStatus{status: ProposalDropped, reason: reason}, nil
+	}
+	return &proposalStatus{status: ProposalUnknown}, nil
+}
+
+// droppedProposals remembers why the most recently dropped proposals were
+// dropped.
+// droppedProposals is not safe for concurrent use; the VM accesses it with
+// the context lock held.
+type droppedProposals struct {
+	maxSize int
+	// proposal ID --> why the proposal was dropped, oldest drop first
+	reasons linkedhashmap.LinkedHashmap[ids.ID, string]
+	// cause --> number of proposals dropped for it
+	counts *prometheus.CounterVec
+}
+
+func newDroppedProposals(maxSize int, counts *prometheus.CounterVec) *droppedProposals {
+	return &droppedProposals{
+		maxSize: maxSize,
+		reasons: linkedhashmap.New[ids.ID, string](),
+		counts:  counts,
+	}
+}
+
+// drop records that [data] was dropped for [reason], counted as [cause],
+// forgetting the oldest drop if too many are remembered
+func (d *droppedProposals) drop(data []byte, cause, reason string) {
+	d.counts.WithLabelValues(cause).Inc()
+	d.reasons.Put(dataID(data), reason)
+	if d.reasons.Len() > d.maxSize {
+		oldestID, _, _ := d.reasons.Oldest()
+		d.reasons.Delete(oldestID)
+	}
+}
+
+// dropAll records that each piece of [entries] was dropped for [reason],
+// counted as [cause]
+func (d *droppedProposals) dropAll(entries [][]byte, cause, reason string) {
+	for _, data := range entries {
+		d.drop(data, cause, reason)
+	}
+}
+
+// reason returns why the proposal [proposalID] was dropped.
+// Returns false if it isn't remembered as dropped.
+func (d *droppedProposals) reason(proposalID ids.ID) (string, bool) {
+	return d.reasons.Get(proposalID)
+}
@@ -0,0 +1,89 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/utils/units"
+)
+
+const (
+	// MaxReceiptBlocks is the maximum number of blocks in a receipt, including
+	// the block holding the data and the checkpoint block
+	MaxReceiptBlocks = 1024
+	// MaxReceiptSize is the maximum total length of the data and the blocks
+	// of a receipt
+	MaxReceiptSize = units.MiB
+)
+
+var (
+	errCheckpointTooFar      = fmt.Errorf("checkpoint block is more than %d blocks after the block holding the data", MaxReceiptBlocks-1)
+	errCheckpointBeforeBlock = errors.New("checkpoint block is before the block holding the data")
+	errReceiptTooLarge       = fmt.Errorf("receipt would be larger than %d bytes, use a closer checkpoint", MaxReceiptSize)
+)
+
+// Receipt shows that a piece of data was accepted in a block, at the block's
+// timestamp, without trusting the node that made it.
+// The blocks are linked by their parent IDs, which are the hashes of their
+// bytes, so a receipt is valid as long as the ID of its last block, the
+// checkpoint, is known to be accepted.
+// The whole blocks are needed, as an inclusion proof against the root of the
+// checkpoint doesn't cover the timestamp of the block holding the data.
+// Receipts are bounded by [MaxReceiptBlocks] and [MaxReceiptSize], so data
+// accepted long before the checkpoint needs a closer checkpoint.
+// Receipts are marshalled with [ReceiptCodec].
+type Receipt struct {
+	// The timestamped data
+	Data []byte `serialize:"true" json:"data"`
+	// Bytes of the block holding the data, followed by its descendants up to
+	// and including the checkpoint block
+	Blks [][]byte `serialize:"true" json:"blocks"`
+}
+
+// newReceipt returns the receipt of [data], accepted in [blk], leading to the
+// accepted block [checkpoint]
+func (vm *VM) newReceipt(data []byte, blk *Block, checkpoint *Block) (*Receipt, error) {
+	if checkpoint.Height() < blk.Height() {
+		return nil, errCheckpointBeforeBlock
+	}
+	numBlks := checkpoint.Height() - blk.Height() + 1
+	if numBlks > MaxReceiptBlocks {
+		return nil, errCheckpointTooFar
+	}
+
+	// Walk back from the checkpoint to the block holding the data, reading no
+	// more blocks than fit in a receipt
+	blks := make([][]byte, numBlks)
+	size := len(data)
+	for i, next := int(numBlks)-1, checkpoint; i >= 0; i-- {
+		size += len(next.Bytes())
+		if size > MaxReceiptSize {
+			return nil, errReceiptTooLarge
+		}
+		blks[i] = next.Bytes()
+		if i == 0 {
+			break
+		}
+		parent, err := vm.getBlock(next.Parent())
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get block %s: %w", next.Parent(), err)
+		}
+		next = parent
+	}
+	return &Receipt{
+		Data: data,
+		Blks: blks,
+	}, nil
+}
+
+// UnmarshalBlock unmarshals [bytes] into a block of the version [bytes] are
+// encoded with, outside of any VM, so blocks can be inspected by tools that
+// don't run a node. The ID of the block is the hash of [bytes], as it is for
+// the blocks of a VM.
+func UnmarshalBlock(bytes []byte) (*Block, error) {
+	return parseBlock(bytes, choices.Unknown, nil)
+}
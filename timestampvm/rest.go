@@ -0,0 +1,235 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	stdjson "encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path"
+	"strconv"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/utils/json"
+)
+
+// The paths of the REST API, relative to the chain's API. The node routes
+// them as gorilla/mux templates, so {id} and {height} match a path segment.
+const (
+	BlockEndpoint         = "/blocks/{id}"
+	BlockAtHeightEndpoint = "/blocks/height/{height}"
+	ProposalsEndpoint     = "/proposals"
+	ProposalEndpoint      = "/proposals/{id}"
+
+	// Segment of [BlockEndpoint] standing for the last accepted block
+	latestBlock = "latest"
+
+	// Maximum size of the body of a proposal: hex encoded data and signature
+	maxProposalBodySize = 4 * MaxDataLen
+
+	// Accepted blocks, and the blocks at accepted heights, never change
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+var (
+	errBadBlockID    = errors.New("block ID isn't valid")
+	errBadHeight     = errors.New("height isn't a number")
+	errBadProposalID = errors.New("proposal ID isn't valid")
+	errBadBody       = errors.New("body isn't a JSON proposal")
+)
+
+// restHandler serves the block and proposal methods of [Service] as REST
+// resources, replying with HTTP status codes instead of JSON-RPC errors
+type restHandler struct {
+	service *Service
+}
+
+func newRESTHandler(service *Service) *restHandler {
+	return &restHandler{service: service}
+}
+
+// handlers returns the handlers of the REST API by their path
+func (h *restHandler) handlers() map[string]*common.HTTPHandler {
+	return map[string]*common.HTTPHandler{
+		BlockEndpoint: {
+			LockOptions: common.ReadLock,
+			Handler:     h.handle(http.MethodGet, BlockEndpoint, h.getBlock),
+		},
+		BlockAtHeightEndpoint: {
+			LockOptions: common.ReadLock,
+			Handler:     h.handle(http.MethodGet, BlockAtHeightEndpoint, h.getBlockByHeight),
+		},
+		ProposalsEndpoint: {
+			LockOptions: common.WriteLock,
+			Handler:     h.handle(http.MethodPost, ProposalsEndpoint, h.propose),
+		},
+		ProposalEndpoint: {
+			LockOptions: common.ReadLock,
+			Handler:     h.handle(http.MethodGet, ProposalEndpoint, h.getProposalStatus),
+		},
+	}
+}
+
+// getBlock replies with the block whose ID ends the path, or with the last
+// accepted block if the path ends with [latestBlock]
+func (h *restHandler) getBlock(w http.ResponseWriter, r *http.Request) {
+	args := &GetBlockArgs{}
+	if segment := path.Base(r.URL.Path); segment != latestBlock {
+		id, err := ids.FromString(segment)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %q", errBadBlockID, segment))
+			return
+		}
+		args.ID = &id
+	}
+
+	reply := &GetBlockReply{}
+	if err := h.service.GetBlock(r, args, reply); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	if args.ID == nil {
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Cache-Control", immutableCacheControl)
+	}
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// getBlockByHeight replies with the block accepted at the height ending the
+// path
+func (h *restHandler) getBlockByHeight(w http.ResponseWriter, r *http.Request) {
+	segment := path.Base(r.URL.Path)
+	height, err := strconv.ParseUint(segment, 10, 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %q", errBadHeight, segment))
+		return
+	}
+
+	reply := &GetBlockReply{}
+	args := &GetBlockByHeightArgs{Height: json.Uint64(height)}
+	if err := h.service.GetBlockByHeight(r, args, reply); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	w.Header().Set("Cache-Control", immutableCacheControl)
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// propose proposes the data of the JSON body, shaped as [ProposeBlockArgs],
+// and replies with the ID of the proposal once it's queued
+func (h *restHandler) propose(w http.ResponseWriter, r *http.Request) {
+	args := &ProposeBlockArgs{}
+	decoder := stdjson.NewDecoder(http.MaxBytesReader(w, r.Body, maxProposalBodySize))
+	if err := decoder.Decode(args); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %s", errBadBody, err))
+		return
+	}
+
+	reply := &ProposeBlockReply{}
+	if err := h.service.ProposeBlock(r, args, reply); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	if !reply.Success {
+		writeError(w, http.StatusServiceUnavailable, errMempoolFull)
+		return
+	}
+	w.Header().Set("Location", path.Join(r.URL.Path, reply.ProposalID.String()))
+	writeJSON(w, http.StatusAccepted, reply)
+}
+
+// getProposalStatus replies with the status of the proposal whose ID ends
+// the path
+func (h *restHandler) getProposalStatus(w http.ResponseWriter, r *http.Request) {
+	segment := path.Base(r.URL.Path)
+	id, err := ids.FromString(segment)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("%w: %q", errBadProposalID, segment))
+		return
+	}
+
+	reply := &GetProposalStatusReply{}
+	if err := h.service.GetProposalStatus(r, &GetProposalStatusArgs{ProposalID: id}, reply); err != nil {
+		writeError(w, statusCode(err), err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-cache")
+	writeJSON(w, http.StatusOK, reply)
+}
+
+// statusCode returns the HTTP status code reporting [err], an error of
+// [Service]
+func statusCode(err error) int {
+	switch {
+	case errors.Is(err, errNoSuchBlock):
+		return http.StatusNotFound
+	case errors.Is(err, errDuplicateData),
+		errors.Is(err, errAlreadyAccepted),
+		errors.Is(err, errNonceUsed):
+		return http.StatusConflict
+	case errors.Is(err, errNoncesMissing):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, errBadData),
+		errors.Is(err, errBadSignature),
+		errors.Is(err, errInvalidSignature),
+		errors.Is(err, errWrongSigner),
+		errors.Is(err, errEmptyData),
+		errors.Is(err, errDataTooLarge),
+		errors.Is(err, errFixedDataLen),
+		errors.Is(err, errUnsignedOnly):
+		return http.StatusBadRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// handle returns a handler passing the requests with [method] to [handler],
+// and refusing the others. The calls are counted as calls to [endpoint].
+func (h *restHandler) handle(method, endpoint string, handler http.HandlerFunc) http.Handler {
+	name := method + " " + endpoint
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		defer func() {
+			h.service.vm.metrics.observeAPIMethod(name, recorder.status >= http.StatusBadRequest)
+		}()
+
+		if r.Method != method {
+			recorder.Header().Set("Allow", method)
+			writeError(recorder, http.StatusMethodNotAllowed, fmt.Errorf("method %s isn't allowed", r.Method))
+			return
+		}
+		handler(recorder, r)
+	})
+}
+
+// statusRecorder records the status code of a reply
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+// restError is the body of the replies reporting an error
+type restError struct {
+	Error string `json:"error"`
+}
+
+// writeError replies with [status] and [err]
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, &restError{Error: err.Error()})
+}
+
+// writeJSON replies with [status] and [body] encoded as JSON
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = stdjson.NewEncoder(w).Encode(body)
+}
@@ -4,18 +4,33 @@
 package timestampvm
 
 import (
+	"bytes"
 	"errors"
+	"fmt"
+	"math"
 	"net/http"
 
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/utils/formatting"
 	"github.com/ava-labs/avalanchego/utils/json"
 )
 
 var (
-	errBadData               = errors.New("data must be hex representation of 32 bytes")
+	errBadData               = errors.New("data must be hex encoded")
+	errBadSignature          = errors.New("signature must be hex encoded")
+	errInvalidSignature      = errors.New("signature is invalid")
 	errNoSuchBlock           = errors.New("couldn't get block from database. Does it exist?")
 	errCannotGetLastAccepted = errors.New("problem getting last accepted")
+	errDataNotInBlock        = errors.New("data isn't in the block")
+	errRootBeforeData        = errors.New("root block is before the block holding the data")
+	errDataNotFound          = errors.New("data was never accepted")
+	errBadTimeRange          = errors.New("time range ends before it starts")
+	errBadCursor             = errors.New("cursor isn't the height of an accepted block")
+	errBadBlockRange         = errors.New("block range is empty, or has both an end height and a count")
+	errNoBlockBefore         = errors.New("no block was accepted at or before that time")
+	errBatchTooLarge         = errors.New("batch holds more proposals than allowed")
+	errNoRoot                = fmt.Errorf("root block doesn't commit to an accumulator root until the %s upgrade", MerkleRootUpgrade)
 )
 
 // Service is the API service for this VM
@@ -23,21 +38,53 @@ type Service struct{ vm *VM }
 
 // ProposeBlockArgs are the arguments to function ProposeValue
 type ProposeBlockArgs struct {
-	// Data in the block. Must be hex encoding of 32 bytes.
+	// Data in the block. Must be hex encoding of at most the configured
+	// maximum data length.
 	Data string `json:"data"`
+	// Optional hex encoded recoverable secp256k1 signature of the hash of
+	// the chain ID, [Nonce] and the data, recording its signer on chain
+	Signature string `json:"signature"`
+	// Nonce of the signer, used at most once. Ignored for unsigned data.
+	Nonce json.Uint64 `json:"nonce"`
 }
 
 // ProposeBlockReply is the reply from function ProposeBlock
-type ProposeBlockReply struct{ Success bool }
+type ProposeBlockReply struct {
+	Success bool
+	// ID of the proposal, to follow it with GetProposalStatus
+	ProposalID ids.ID `json:"proposalID"`
+}
 
 // ProposeBlock is an API method to propose a new block whose data is [args].Data.
-// [args].Data must be a string repr. of a 32 byte array
+// [args].Data must be the hex repr. of at most the configured maximum data
+// length; longer data is rejected with an error
 func (s *Service) ProposeBlock(_ *http.Request, args *ProposeBlockArgs, reply *ProposeBlockReply) error {
 	bytes, err := formatting.Decode(formatting.Hex, args.Data)
-	if err != nil || len(bytes) != DataLen {
+	if err != nil {
 		return errBadData
 	}
-	reply.Success = s.vm.proposeBlock(BytesToData(bytes))
+	var sub *Submission
+	if args.Signature != "" {
+		sig, err := formatting.Decode(formatting.Hex, args.Signature)
+		if err != nil {
+			return errBadSignature
+		}
+		sub, err = NewSubmission(s.vm.snowCtx.ChainID, uint64(args.Nonce), bytes, sig)
+		if err != nil {
+			return fmt.Errorf("%w: %s", errInvalidSignature, err)
+		}
+	}
+	reply.ProposalID = ProposalID(bytes)
+	switch err := s.vm.proposeSignedBlock(bytes, sub); err {
+	case nil:
+		reply.Success = true
+	case errMempoolFull:
+		// A full mempool is reported as an unsuccessful proposal, so callers
+		// can retry later
+		reply.Success = false
+	default:
+		return err
+	}
 	return nil
 }
 
@@ -50,11 +97,15 @@ type GetBlockArgs struct {
 
 // GetBlockReply is the reply from GetBlock
 type GetBlockReply struct {
-	Timestamp json.Uint64 `json:"timestamp"` // Timestamp of block
-	Data      string      `json:"data"`      // Data (hex-encoded) in block
-	Height    json.Uint64 `json:"height"`    // Height of block
-	ID        ids.ID      `json:"id"`        // String repr. of ID of block
-	ParentID  ids.ID      `json:"parentID"`  // String repr. of ID of block's parent
+	Timestamp   json.Uint64 `json:"timestamp"`   // Timestamp of block, in Unix seconds
+	TimestampMs json.Uint64 `json:"timestampMs"` // Timestamp of block, in Unix milliseconds
+	Data        []string    `json:"data"`        // Data (hex-encoded) in block
+	Height      json.Uint64 `json:"height"`      // Height of block
+	ID          ids.ID      `json:"id"`          // String repr. of ID of block
+	ParentID    ids.ID      `json:"parentID"`    // String repr. of ID of block's parent
+	// Address of the signer of each piece of data, empty for unsigned data.
+	// Omitted for blocks before the signedData upgrade.
+	Signers []string `json:"signers,omitempty"`
 }
 
 // GetBlock gets the block whose ID is [args.ID]
@@ -76,19 +127,647 @@ func (s *Service) GetBlock(_ *http.Request, args *GetBlockArgs, reply *GetBlockR
 		id = *args.ID
 	}
 
+	return s.getBlock(id, reply)
+}
+
+// GetBlockByHeightArgs are the arguments to GetBlockByHeight
+type GetBlockByHeightArgs struct {
+	// Height of the accepted block we're getting
+	Height json.Uint64 `json:"height"`
+}
+
+// GetBlockByHeight gets the accepted block whose height is [args.Height]
+func (s *Service) GetBlockByHeight(_ *http.Request, args *GetBlockByHeightArgs, reply *GetBlockReply) error {
+	id, err := s.vm.state.GetBlockIDAtHeight(uint64(args.Height))
+	if err != nil {
+		return errNoSuchBlock
+	}
+
+	return s.getBlock(id, reply)
+}
+
+// ProposeBatchArgs are the arguments to ProposeBatch
+type ProposeBatchArgs struct {
+	// Data (hex-encoded) of each proposal, at most the configured maximum
+	// batch size of them
+	Data []string `json:"data"`
+}
+
+// ProposeBatchResult is the result of a proposal of a batch
+type ProposeBatchResult struct {
+	// ID of the proposal, empty if its data isn't hex
+	ProposalID ids.ID         `json:"proposalID"`
+	Result     ProposalResult `json:"result"`
+	// Why the data is invalid, if it is
+	Error string `json:"error,omitempty"`
+}
+
+// ProposeBatchReply is the reply from ProposeBatch
+type ProposeBatchReply struct {
+	// Result of each proposal, in the order of the data
+	Results []ProposeBatchResult `json:"results"`
+}
+
+// ProposeBatch proposes each piece of [args].Data like ProposeBlock, in a
+// single call. Each proposal succeeds or fails on its own.
+func (s *Service) ProposeBatch(_ *http.Request, args *ProposeBatchArgs, reply *ProposeBatchReply) error {
+	if len(args.Data) > s.vm.config.MaxBatchSize {
+		return fmt.Errorf("%w: %d > %d", errBatchTooLarge, len(args.Data), s.vm.config.MaxBatchSize)
+	}
+
+	reply.Results = make([]ProposeBatchResult, len(args.Data))
+	var (
+		batch   = make([][]byte, 0, len(args.Data))
+		indices = make([]int, 0, len(args.Data))
+	)
+	for i, encoded := range args.Data {
+		data, err := formatting.Decode(formatting.Hex, encoded)
+		if err != nil {
+			reply.Results[i] = ProposeBatchResult{
+				Result: ProposalInvalid,
+				Error:  errBadData.Error(),
+			}
+			continue
+		}
+		reply.Results[i].ProposalID = ProposalID(data)
+		batch = append(batch, data)
+		indices = append(indices, i)
+	}
+
+	for j, err := range s.vm.proposeBatch(batch) {
+		result := &reply.Results[indices[j]]
+		switch err {
+		case nil:
+			result.Result = ProposalQueued
+		case errDuplicateData:
+			result.Result = ProposalDuplicate
+		case errAlreadyAccepted:
+			result.Result = ProposalAlreadyAccepted
+		case errMempoolFull:
+			result.Result = ProposalMempoolFull
+		default:
+			result.Result = ProposalInvalid
+			result.Error = err.Error()
+		}
+	}
+	return nil
+}
+
+// GetProposalStatusArgs are the arguments to GetProposalStatus
+type GetProposalStatusArgs struct {
+	// ID of the proposal, as returned by ProposeBlock
+	ProposalID ids.ID `json:"proposalID"`
+}
+
+// GetProposalStatusReply is the reply from GetProposalStatus
+type GetProposalStatusReply struct {
+	Status ProposalStatus `json:"status"`
+	// ID and height of the block holding the data, if the proposal is
+	// processing or accepted
+	BlockID ids.ID      `json:"blockID"`
+	Height  json.Uint64 `json:"height"`
+	// Why the data was dropped, if the proposal was dropped
+	Reason string `json:"reason,omitempty"`
+}
+
+// GetProposalStatus gets what became of the proposal [args.ProposalID] on
+// this node. Dropped proposals are only remembered in memory, for a while.
+func (s *Service) GetProposalStatus(_ *http.Request, args *GetProposalStatusArgs, reply *GetProposalStatusReply) error {
+	status, err := s.vm.getProposalStatus(args.ProposalID)
+	if err != nil {
+		return err
+	}
+	reply.Status = status.status
+	reply.BlockID = ids.Empty
+	reply.Height = 0
+	if status.blk != nil {
+		reply.BlockID = status.blk.ID()
+		reply.Height = json.Uint64(status.blk.Height())
+	}
+	reply.Reason = status.reason
+	return nil
+}
+
+// GetBlockByDataArgs are the arguments to GetBlockByData
+type GetBlockByDataArgs struct {
+	// Data (hex-encoded) to look up
+	Data string `json:"data"`
+}
+
+// GetBlockByData gets the earliest accepted block holding [args.Data]
+func (s *Service) GetBlockByData(_ *http.Request, args *GetBlockByDataArgs, reply *GetBlockReply) error {
+	data, err := formatting.Decode(formatting.Hex, args.Data)
+	if err != nil {
+		return errBadData
+	}
+	id, err := s.vm.state.GetBlockIDByData(data)
+	if err != nil {
+		return errDataNotFound
+	}
+
+	return s.getBlock(id, reply)
+}
+
+// GetBlocksByTimeRangeArgs are the arguments to GetBlocksByTimeRange
+type GetBlocksByTimeRangeArgs struct {
+	// Start of the time range, in Unix seconds, included
+	Start json.Uint64 `json:"start"`
+	// End of the time range, in Unix seconds, excluded
+	End json.Uint64 `json:"end"`
+	// Maximum number of blocks returned. If left blank, or greater than the
+	// configured maximum blocks per page, at most that many blocks are
+	// returned.
+	Limit json.Uint32 `json:"limit"`
+	// Height of the next block to return, from a previous reply.
+	// If left blank, blocks are returned from the start of the time range.
+	Cursor *json.Uint64 `json:"cursor"`
+}
+
+// GetBlocksByTimeRangeReply is the reply from GetBlocksByTimeRange
+type GetBlocksByTimeRangeReply struct {
+	Blocks     []GetBlockReply `json:"blocks"`     // Blocks in the time range, in height order
+	NextCursor *json.Uint64    `json:"nextCursor"` // Cursor of the next blocks, if there are more
+}
+
+// GetBlocksByTimeRange gets the accepted blocks whose timestamps are in
+// [[args.Start], [args.End]), a page at a time.
+// If there are more blocks than fit in the reply, [reply.NextCursor] is
+// passed as [args.Cursor] to get the next ones.
+func (s *Service) GetBlocksByTimeRange(_ *http.Request, args *GetBlocksByTimeRangeArgs, reply *GetBlocksByTimeRangeReply) error {
+	if args.End < args.Start {
+		return errBadTimeRange
+	}
+	limit := s.pageLimit(args.Limit)
+
+	// Resume from the cursor's block, which is in the time range
+	var (
+		from       = unixTimestamp(args.Start)
+		fromHeight = uint64(0)
+	)
+	if args.Cursor != nil {
+		cursorBlk, err := s.getAcceptedBlock(uint64(*args.Cursor))
+		if err != nil {
+			return errBadCursor
+		}
+		fromHeight = cursorBlk.Height()
+		if cursorBlk.Tmstmp > from {
+			from = cursorBlk.Tmstmp
+		}
+	}
+
+	// Get one more block than returned, to know if there are more
+	blkIDs, err := s.vm.state.GetBlockIDsByTime(from, fromHeight, unixTimestamp(args.End), limit+1)
+	if err != nil {
+		return err
+	}
+	reply.NextCursor = nil
+	if len(blkIDs) > limit {
+		next, err := s.vm.getBlock(blkIDs[limit])
+		if err != nil {
+			return errNoSuchBlock
+		}
+		nextCursor := json.Uint64(next.Height())
+		reply.NextCursor = &nextCursor
+		blkIDs = blkIDs[:limit]
+	}
+
+	reply.Blocks = make([]GetBlockReply, len(blkIDs))
+	for i, blkID := range blkIDs {
+		if err := s.getBlock(blkID, &reply.Blocks[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetBlocksArgs are the arguments to GetBlocks
+type GetBlocksArgs struct {
+	// Height of the first block to return. If left blank, blocks are returned
+	// from genesis, or from the last accepted block if [Descending].
+	StartHeight *json.Uint64 `json:"startHeight"`
+	// Height of the last block to return, included. If left blank, blocks are
+	// returned up to the last accepted block, or down to genesis if
+	// [Descending]. Can't be given along with [Count].
+	EndHeight *json.Uint64 `json:"endHeight"`
+	// Number of blocks to return from [StartHeight], over all the pages.
+	// Can't be given along with [EndHeight].
+	Count *json.Uint64 `json:"count"`
+	// Whether blocks are returned from the highest to the lowest height
+	Descending bool `json:"descending"`
+	// Maximum number of blocks returned. If left blank, or greater than the
+	// configured maximum blocks per page, at most that many blocks are
+	// returned.
+	Limit json.Uint32 `json:"limit"`
+	// Height of the next block to return, from a previous reply.
+	// If left blank, blocks are returned from [StartHeight].
+	Cursor *json.Uint64 `json:"cursor"`
+}
+
+// GetBlocksReply is the reply from GetBlocks
+type GetBlocksReply struct {
+	Blocks     []GetBlockReply `json:"blocks"`     // Blocks in the height range, in the requested order
+	NextCursor *json.Uint64    `json:"nextCursor"` // Cursor of the next blocks, if there are more
+}
+
+// GetBlocks gets the accepted blocks from [args.StartHeight] to
+// [args.EndHeight], or [args.Count] blocks from [args.StartHeight], in
+// ascending or descending height order, a page at a time.
+// Heights after the last accepted block are left out.
+// If there are more blocks than fit in the reply, [reply.NextCursor] is
+// passed as [args.Cursor], along with the same range, to get the next ones.
+func (s *Service) GetBlocks(_ *http.Request, args *GetBlocksArgs, reply *GetBlocksReply) error {
+	if args.EndHeight != nil && args.Count != nil {
+		return errBadBlockRange
+	}
+	lastBlk, err := s.getAcceptedBlockOrLast(nil)
+	if err != nil {
+		return err
+	}
+	lastHeight := lastBlk.Height()
+
+	// Get the range of heights, from [low] to [high] included
+	if args.Count != nil && *args.Count == 0 {
+		reply.Blocks = []GetBlockReply{}
+		reply.NextCursor = nil
+		return nil
+	}
+	var low, high uint64
+	if args.Descending {
+		high = lastHeight
+		if args.StartHeight != nil {
+			high = uint64(*args.StartHeight)
+		}
+		switch {
+		case args.EndHeight != nil:
+			low = uint64(*args.EndHeight)
+		case args.Count != nil && uint64(*args.Count) <= high:
+			low = high - uint64(*args.Count) + 1
+		}
+	} else {
+		high = math.MaxUint64
+		if args.StartHeight != nil {
+			low = uint64(*args.StartHeight)
+		}
+		switch {
+		case args.EndHeight != nil:
+			high = uint64(*args.EndHeight)
+		case args.Count != nil && uint64(*args.Count)-1 <= math.MaxUint64-low:
+			high = low + uint64(*args.Count) - 1
+		}
+	}
+	if high < low {
+		return errBadBlockRange
+	}
+
+	// Heights after the last accepted block are left out
+	if low > lastHeight {
+		reply.Blocks = []GetBlockReply{}
+		reply.NextCursor = nil
+		return nil
+	}
+	if high > lastHeight {
+		high = lastHeight
+	}
+
+	// Resume from the cursor's block, which must be in the range
+	next := low
+	if args.Descending {
+		next = high
+	}
+	if args.Cursor != nil {
+		next = uint64(*args.Cursor)
+		if next < low || next > high {
+			return errBadCursor
+		}
+	}
+
+	limit := s.pageLimit(args.Limit)
+	reply.Blocks = make([]GetBlockReply, 0, limit)
+	reply.NextCursor = nil
+	for {
+		id, err := s.vm.state.GetBlockIDAtHeight(next)
+		if err != nil {
+			return errNoSuchBlock
+		}
+		var blkReply GetBlockReply
+		if err := s.getBlock(id, &blkReply); err != nil {
+			return err
+		}
+		reply.Blocks = append(reply.Blocks, blkReply)
+
+		if (args.Descending && next == low) || (!args.Descending && next == high) {
+			return nil
+		}
+		if args.Descending {
+			next--
+		} else {
+			next++
+		}
+		if len(reply.Blocks) == limit {
+			nextCursor := json.Uint64(next)
+			reply.NextCursor = &nextCursor
+			return nil
+		}
+	}
+}
+
+// pageLimit returns the number of blocks a page holds, given the [limit]
+// requested
+func (s *Service) pageLimit(limit json.Uint32) int {
+	if limit == 0 || int(limit) > s.vm.config.MaxBlocksPerPage {
+		return s.vm.config.MaxBlocksPerPage
+	}
+	return int(limit)
+}
+
+// GetBlockAtTimeArgs are the arguments to GetBlockAtTime
+type GetBlockAtTimeArgs struct {
+	// Time, in Unix seconds
+	Time json.Uint64 `json:"time"`
+}
+
+// GetBlockAtTime gets the latest accepted block whose timestamp is at or
+// before [args.Time], which was the last accepted block of the chain then
+func (s *Service) GetBlockAtTime(_ *http.Request, args *GetBlockAtTimeArgs, reply *GetBlockReply) error {
+	// Accepted blocks aren't earlier than their parent, so the block is the
+	// parent of the earliest one after [args.Time], if any
+	var id ids.ID
+	afterID, err := s.vm.state.GetBlockIDAfter(unixTimestamp(args.Time))
+	switch err {
+	case nil:
+		after, err := s.vm.getBlock(afterID)
+		if err != nil {
+			return errNoSuchBlock
+		}
+		if after.Height() == 0 {
+			return errNoBlockBefore
+		}
+		id, err = s.vm.state.GetBlockIDAtHeight(after.Height() - 1)
+		if err != nil {
+			return errNoSuchBlock
+		}
+	case database.ErrNotFound:
+		id, err = s.vm.state.GetLastAccepted()
+		if err != nil {
+			return errCannotGetLastAccepted
+		}
+	default:
+		return err
+	}
+
+	return s.getBlock(id, reply)
+}
+
+// unixTimestamp returns [t] as a block timestamp, capped to the latest
+// timestamp a block can have
+func unixTimestamp(t json.Uint64) int64 {
+	if t > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(t)
+}
+
+// getBlock fills out [reply] with the block whose ID is [id]
+func (s *Service) getBlock(id ids.ID, reply *GetBlockReply) error {
 	// Get the block from the database
 	block, err := s.vm.getBlock(id)
 	if err != nil {
 		return errNoSuchBlock
 	}
+	return fillBlockReply(block, reply)
+}
 
-	// Fill out the response with the block's data
+// fillBlockReply fills out [reply] with the data of [block]
+func fillBlockReply(block *Block, reply *GetBlockReply) error {
 	reply.Timestamp = json.Uint64(block.Timestamp().Unix())
-	data := block.Data()
-	reply.Data, err = formatting.Encode(formatting.Hex, data[:])
+	reply.TimestampMs = json.Uint64(block.Timestamp().UnixMilli())
+	entries := block.Entries()
+	reply.Data = make([]string, len(entries))
+	for i, data := range entries {
+		encoded, err := formatting.Encode(formatting.Hex, data)
+		if err != nil {
+			return err
+		}
+		reply.Data[i] = encoded
+	}
+	reply.Signers = nil
+	if subs := block.Submissions(); len(subs) > 0 {
+		reply.Signers = make([]string, len(subs))
+		for i, sub := range subs {
+			if sub.IsSigned() {
+				reply.Signers[i] = sub.Signer.String()
+			}
+		}
+	}
 	reply.Height = json.Uint64(block.Hght)
 	reply.ID = block.ID()
 	reply.ParentID = block.Parent()
 
+	return nil
+}
+
+// GetInclusionProofArgs are the arguments to GetInclusionProof
+type GetInclusionProofArgs struct {
+	// Height of the accepted block holding the data
+	Height json.Uint64 `json:"height"`
+	// Data (hex-encoded) to prove the inclusion of
+	Data string `json:"data"`
+	// Height of the accepted block whose root the proof leads to.
+	// If left blank, the proof leads to the root of the last accepted block.
+	RootHeight *json.Uint64 `json:"rootHeight"`
+}
+
+// GetInclusionProofReply is the reply from GetInclusionProof
+type GetInclusionProofReply struct {
+	BlockID ids.ID         `json:"blockID"` // ID of the block whose root the proof leads to
+	Height  json.Uint64    `json:"height"`  // Height of the block whose root the proof leads to
+	Root    ids.ID         `json:"root"`    // Accumulator root of that block
+	Proof   InclusionProof `json:"proof"`   // Proof that the data is in the accumulator
+}
+
+// GetInclusionProof gets the proof that [args.Data], accepted in the block at
+// [args.Height], is in the accumulator whose root is committed to by the block
+// at [args.RootHeight].
+// If [args.RootHeight] is empty, the proof leads to the root of the latest
+// block.
+func (s *Service) GetInclusionProof(_ *http.Request, args *GetInclusionProofArgs, reply *GetInclusionProofReply) error {
+	// Get the block holding the data, and the index of the data in the
+	// accumulator
+	data, blk, index, err := s.getDataBlock(args.Height, args.Data)
+	if err != nil {
+		return err
+	}
+	firstIndex := uint64(0)
+	if blk.Height() > 0 {
+		firstIndex, err = s.vm.state.GetAccumulatorSizeAtHeight(blk.Height() - 1)
+		if err != nil {
+			return fmt.Errorf("couldn't get accumulator size at height %d: %w", blk.Height()-1, err)
+		}
+	}
+
+	// Get the block whose root the proof leads to
+	rootBlk, err := s.getAcceptedBlockOrLast(args.RootHeight)
+	if err != nil {
+		return err
+	}
+	if rootBlk.Height() < blk.Height() {
+		return errRootBeforeData
+	}
+	if rootBlk.Version() < BlockVersion3 {
+		return errNoRoot
+	}
+
+	proof, err := s.vm.state.GetInclusionProof(firstIndex+uint64(index), rootBlk.Sz)
+	if err != nil {
+		return fmt.Errorf("couldn't get inclusion proof: %w", err)
+	}
+	// The data before a state summary this node synced to can't be proven
+	if err := proof.Verify(data, rootBlk.Rt); err != nil {
+		return fmt.Errorf("couldn't prove inclusion: %w", err)
+	}
+
+	reply.BlockID = rootBlk.ID()
+	reply.Height = json.Uint64(rootBlk.Height())
+	reply.Root = rootBlk.Rt
+	reply.Proof = *proof
+	return nil
+}
+
+// GetReceiptArgs are the arguments to GetReceipt
+type GetReceiptArgs struct {
+	// Height of the accepted block holding the data
+	Height json.Uint64 `json:"height"`
+	// Data (hex-encoded) to get the receipt of
+	Data string `json:"data"`
+	// Height of the accepted block the receipt leads to.
+	// If left blank, the receipt leads to the last accepted block.
+	CheckpointHeight *json.Uint64 `json:"checkpointHeight"`
+}
+
+// GetReceiptReply is the reply from GetReceipt
+type GetReceiptReply struct {
+	Receipt          string      `json:"receipt"`          // Receipt (hex-encoded)
+	CheckpointID     ids.ID      `json:"checkpointID"`     // ID of the block the receipt leads to
+	CheckpointHeight json.Uint64 `json:"checkpointHeight"` // Height of the block the receipt leads to
+}
+
+// GetReceipt gets the receipt of [args.Data], accepted in the block at
+// [args.Height], leading to the block at [args.CheckpointHeight].
+// If [args.CheckpointHeight] is empty, the receipt leads to the latest block.
+func (s *Service) GetReceipt(_ *http.Request, args *GetReceiptArgs, reply *GetReceiptReply) error {
+	data, blk, _, err := s.getDataBlock(args.Height, args.Data)
+	if err != nil {
+		return err
+	}
+	checkpoint, err := s.getAcceptedBlockOrLast(args.CheckpointHeight)
+	if err != nil {
+		return err
+	}
+
+	receipt, err := s.vm.newReceipt(data, blk, checkpoint)
+	if err != nil {
+		return err
+	}
+	receiptBytes, err := ReceiptCodec.Marshal(CodecVersion, receipt)
+	if err != nil {
+		return err
+	}
+
+	reply.Receipt, err = formatting.Encode(formatting.Hex, receiptBytes)
+	if err != nil {
+		return err
+	}
+	reply.CheckpointID = checkpoint.ID()
+	reply.CheckpointHeight = json.Uint64(checkpoint.Height())
+	return nil
+}
+
+// GetWarpSignatureArgs are the arguments to GetWarpSignature
+type GetWarpSignatureArgs struct {
+	// Height of the accepted block holding the data
+	Height json.Uint64 `json:"height"`
+	// Data (hex-encoded) to get the signature of
+	Data string `json:"data"`
+}
+
+// GetWarpSignatureReply is the reply from GetWarpSignature
+type GetWarpSignatureReply struct {
+	MessageID ids.ID `json:"messageID"` // ID of the unsigned Warp message
+	Message   string `json:"message"`   // Unsigned Warp message (hex-encoded)
+	Signature string `json:"signature"` // BLS signature of this node over the message (hex-encoded)
+}
+
+// GetWarpSignature gets the unsigned Warp message stating that [args.Data]
+// was accepted in the block at [args.Height], along with the signature of
+// this node over it
+func (s *Service) GetWarpSignature(_ *http.Request, args *GetWarpSignatureArgs, reply *GetWarpSignatureReply) error {
+	data, blk, _, err := s.getDataBlock(args.Height, args.Data)
+	if err != nil {
+		return err
+	}
+	msg, err := NewWarpMessage(s.vm.snowCtx.ChainID, blk, data)
+	if err != nil {
+		return err
+	}
+	sig, err := s.vm.getWarpSignature(msg)
+	if err != nil {
+		return err
+	}
+
+	reply.MessageID = msg.ID()
+	reply.Message, err = formatting.Encode(formatting.Hex, msg.Bytes())
+	if err != nil {
+		return err
+	}
+	reply.Signature, err = formatting.Encode(formatting.Hex, sig)
 	return err
 }
+
+// getDataBlock decodes [hexData] and returns it, along with the accepted block
+// whose height is [height] and the index of the data in that block
+func (s *Service) getDataBlock(height json.Uint64, hexData string) ([]byte, *Block, int, error) {
+	data, err := formatting.Decode(formatting.Hex, hexData)
+	if err != nil {
+		return nil, nil, 0, errBadData
+	}
+	blk, err := s.getAcceptedBlock(uint64(height))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	for i, entry := range blk.Entries() {
+		if bytes.Equal(entry, data) {
+			return data, blk, i, nil
+		}
+	}
+	return nil, nil, 0, errDataNotInBlock
+}
+
+// getAcceptedBlockOrLast returns the accepted block whose height is [height],
+// or the last accepted block if [height] is nil
+func (s *Service) getAcceptedBlockOrLast(height *json.Uint64) (*Block, error) {
+	if height != nil {
+		return s.getAcceptedBlock(uint64(*height))
+	}
+	lastAccepted, err := s.vm.state.GetLastAccepted()
+	if err != nil {
+		return nil, errCannotGetLastAccepted
+	}
+	blk, err := s.vm.getBlock(lastAccepted)
+	if err != nil {
+		return nil, errNoSuchBlock
+	}
+	return blk, nil
+}
+
+// getAcceptedBlock returns the accepted block whose height is [height]
+func (s *Service) getAcceptedBlock(height uint64) (*Block, error) {
+	id, err := s.vm.state.GetBlockIDAtHeight(height)
+	if err != nil {
+		return nil, errNoSuchBlock
+	}
+	blk, err := s.vm.getBlock(id)
+	if err != nil {
+		return nil, errNoSuchBlock
+	}
+	return blk, nil
+}
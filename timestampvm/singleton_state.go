@@ -3,15 +3,32 @@
 
 package timestampvm
 
-import "github.com/ava-labs/avalanchego/database"
+import (
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+)
 
 const (
 	IsInitializedKey byte = iota
+	IsHeightIndexedKey
+	MissingBlockIDKey
+	IsRejectedIndexedKey
+	IsDataAccumulatedKey
+	IsDataIndexedKey
+	IsTimeIndexedKey
+	NoncesMissingKey
 )
 
 var (
-	isInitializedKey                = []byte{IsInitializedKey}
-	_                SingletonState = (*singletonState)(nil)
+	isInitializedKey                    = []byte{IsInitializedKey}
+	isHeightIndexedKey                  = []byte{IsHeightIndexedKey}
+	missingBlockIDKey                   = []byte{MissingBlockIDKey}
+	isRejectedIndexedKey                = []byte{IsRejectedIndexedKey}
+	isDataAccumulatedKey                = []byte{IsDataAccumulatedKey}
+	isDataIndexedKey                    = []byte{IsDataIndexedKey}
+	isTimeIndexedKey                    = []byte{IsTimeIndexedKey}
+	noncesMissingKey                    = []byte{NoncesMissingKey}
+	_                    SingletonState = (*singletonState)(nil)
 )
 
 // SingletonState is a thin wrapper around a database to provide, caching,
@@ -19,6 +36,44 @@ var (
 type SingletonState interface {
 	IsInitialized() (bool, error)
 	SetInitialized() error
+
+	// IsHeightIndexed returns true if every accepted block is in the height
+	// index
+	IsHeightIndexed() (bool, error)
+	SetHeightIndexed() error
+
+	// GetMissingBlockID returns the ID of the most recent accepted block that
+	// isn't stored yet after state syncing, or database.ErrNotFound if there
+	// is none
+	GetMissingBlockID() (ids.ID, error)
+	SetMissingBlockID(blkID ids.ID) error
+	DeleteMissingBlockID() error
+
+	// IsRejectedIndexed returns true if every stored rejected block is in the
+	// index of rejected blocks to prune
+	IsRejectedIndexed() (bool, error)
+	SetRejectedIndexed() error
+
+	// IsDataAccumulated returns true if the data of every accepted block is in
+	// the accumulator
+	IsDataAccumulated() (bool, error)
+	SetDataAccumulated() error
+
+	// IsDataIndexed returns true if the data of every accepted block is
+	// indexed
+	IsDataIndexed() (bool, error)
+	SetDataIndexed() error
+
+	// IsTimeIndexed returns true if every accepted block is indexed by its
+	// timestamp
+	IsTimeIndexed() (bool, error)
+	SetTimeIndexed() error
+
+	// AreNoncesMissing returns true if blocks missing after state syncing
+	// may use nonces that aren't indexed yet
+	AreNoncesMissing() (bool, error)
+	SetNoncesMissing() error
+	DeleteNoncesMissing() error
 }
 
 type singletonState struct {
@@ -38,3 +93,67 @@ func (s *singletonState) IsInitialized() (bool, error) {
 func (s *singletonState) SetInitialized() error {
 	return s.singletonDB.Put(isInitializedKey, nil)
 }
+
+func (s *singletonState) IsHeightIndexed() (bool, error) {
+	return s.singletonDB.Has(isHeightIndexedKey)
+}
+
+func (s *singletonState) SetHeightIndexed() error {
+	return s.singletonDB.Put(isHeightIndexedKey, nil)
+}
+
+func (s *singletonState) GetMissingBlockID() (ids.ID, error) {
+	return database.GetID(s.singletonDB, missingBlockIDKey)
+}
+
+func (s *singletonState) SetMissingBlockID(blkID ids.ID) error {
+	return database.PutID(s.singletonDB, missingBlockIDKey, blkID)
+}
+
+func (s *singletonState) DeleteMissingBlockID() error {
+	return s.singletonDB.Delete(missingBlockIDKey)
+}
+
+func (s *singletonState) IsRejectedIndexed() (bool, error) {
+	return s.singletonDB.Has(isRejectedIndexedKey)
+}
+
+func (s *singletonState) SetRejectedIndexed() error {
+	return s.singletonDB.Put(isRejectedIndexedKey, nil)
+}
+
+func (s *singletonState) IsDataAccumulated() (bool, error) {
+	return s.singletonDB.Has(isDataAccumulatedKey)
+}
+
+func (s *singletonState) SetDataAccumulated() error {
+	return s.singletonDB.Put(isDataAccumulatedKey, nil)
+}
+
+func (s *singletonState) IsDataIndexed() (bool, error) {
+	return s.singletonDB.Has(isDataIndexedKey)
+}
+
+func (s *singletonState) SetDataIndexed() error {
+	return s.singletonDB.Put(isDataIndexedKey, nil)
+}
+
+func (s *singletonState) IsTimeIndexed() (bool, error) {
+	return s.singletonDB.Has(isTimeIndexedKey)
+}
+
+func (s *singletonState) SetTimeIndexed() error {
+	return s.singletonDB.Put(isTimeIndexedKey, nil)
+}
+
+func (s *singletonState) AreNoncesMissing() (bool, error) {
+	return s.singletonDB.Has(noncesMissingKey)
+}
+
+func (s *singletonState) SetNoncesMissing() error {
+	return s.singletonDB.Put(noncesMissingKey, nil)
+}
+
+func (s *singletonState) DeleteNoncesMissing() error {
+	return s.singletonDB.Delete(noncesMissingKey)
+}
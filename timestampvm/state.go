@@ -4,6 +4,8 @@
 package timestampvm
 
 import (
+	"context"
+
 	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/database/prefixdb"
 	"github.com/ava-labs/avalanchego/database/versiondb"
@@ -14,6 +16,12 @@ var (
 	// It's important to set different prefixes for each separate database objects.
 	singletonStatePrefix = []byte("singleton")
 	blockStatePrefix     = []byte("block")
+	heightIndexPrefix    = []byte("height")
+	rejectedIndexPrefix  = []byte("rejected")
+	accumulatorPrefix    = []byte("accumulator")
+	dataIndexPrefix      = []byte("data")
+	timeIndexPrefix      = []byte("time")
+	nonceIndexPrefix     = []byte("nonce")
 
 	_ State = &state{}
 )
@@ -25,14 +33,17 @@ type State interface {
 	// it is used to understand if db is initialized already.
 	SingletonState
 	BlockState
+	AccumulatorState
 
 	Commit() error
 	Close() error
+	HealthCheck(ctx context.Context) (interface{}, error)
 }
 
 type state struct {
 	SingletonState
 	BlockState
+	AccumulatorState
 
 	baseDB *versiondb.Database
 }
@@ -43,14 +54,27 @@ func NewState(db database.Database, vm *VM) State {
 
 	// create a prefixed "blockDB" from baseDB
 	blockDB := prefixdb.New(blockStatePrefix, baseDB)
+	// create a prefixed "heightDB" from baseDB
+	heightDB := prefixdb.New(heightIndexPrefix, baseDB)
+	// create a prefixed "rejectedDB" from baseDB
+	rejectedDB := prefixdb.New(rejectedIndexPrefix, baseDB)
+	// create a prefixed "dataDB" from baseDB
+	dataDB := prefixdb.New(dataIndexPrefix, baseDB)
+	// create a prefixed "timeDB" from baseDB
+	timeDB := prefixdb.New(timeIndexPrefix, baseDB)
+	// create a prefixed "nonceDB" from baseDB
+	nonceDB := prefixdb.New(nonceIndexPrefix, baseDB)
+	// create a prefixed "accumulatorDB" from baseDB
+	accumulatorDB := prefixdb.New(accumulatorPrefix, baseDB)
 	// create a prefixed "singletonDB" from baseDB
 	singletonDB := prefixdb.New(singletonStatePrefix, baseDB)
 
 	// return state with created sub state components
 	return &state{
-		BlockState:     NewBlockState(blockDB, vm),
-		SingletonState: NewSingletonState(singletonDB),
-		baseDB:         baseDB,
+		BlockState:       NewBlockState(blockDB, heightDB, rejectedDB, dataDB, timeDB, nonceDB, vm),
+		AccumulatorState: NewAccumulatorState(accumulatorDB),
+		SingletonState:   NewSingletonState(singletonDB),
+		baseDB:           baseDB,
 	}
 }
 
@@ -59,6 +83,11 @@ func (s *state) Commit() error {
 	return s.baseDB.Commit()
 }
 
+// HealthCheck reports the health of the underlying base database
+func (s *state) HealthCheck(ctx context.Context) (interface{}, error) {
+	return s.baseDB.HealthCheck(ctx)
+}
+
 // Close closes the underlying base database
 func (s *state) Close() error {
 	return s.baseDB.Close()
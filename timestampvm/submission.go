@@ -0,0 +1,135 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+
+	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/utils/crypto/secp256k1"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+)
+
+var (
+	errWrongSigner   = errors.New("signature isn't from the submitter")
+	errNonceUsed     = errors.New("nonce was already used by the submitter")
+	errNoncesMissing = errors.New("nonces of the blocks missing after state syncing aren't indexed yet")
+
+	// recovers the public keys of submitters, caching the most recent ones
+	secpFactory = secp256k1.Factory{
+		Cache: cache.LRU[ids.ID, *secp256k1.PublicKey]{Size: 2048},
+	}
+)
+
+// Submission records who submitted a piece of data, in [BlockVersion4]
+// blocks. The submitter signs the chain ID, a nonce and the data, so the
+// signature can't be replayed on another chain, or on this chain once the
+// nonce is used.
+// The submission of unsigned data is empty.
+type Submission struct {
+	// Address of the submitter
+	Signer ids.ShortID `serialize:"true" json:"signer"`
+	// Nonce of the submitter, used at most once
+	Nonce uint64 `serialize:"true" json:"nonce"`
+	// Recoverable signature of the submitter
+	Sig [secp256k1.SignatureLen]byte `serialize:"true" json:"signature"`
+}
+
+// SignSubmission returns the submission of [data] to the chain [chainID],
+// signed by [key] with [nonce]
+func SignSubmission(key *secp256k1.PrivateKey, chainID ids.ID, nonce uint64, data []byte) (*Submission, error) {
+	sig, err := key.SignHash(submissionHash(chainID, nonce, data))
+	if err != nil {
+		return nil, err
+	}
+	sub := &Submission{
+		Signer: key.Address(),
+		Nonce:  nonce,
+	}
+	copy(sub.Sig[:], sig)
+	return sub, nil
+}
+
+// NewSubmission returns the submission of [data] to the chain [chainID] with
+// [nonce] and [sig], whose signer is recovered from [sig]
+func NewSubmission(chainID ids.ID, nonce uint64, data []byte, sig []byte) (*Submission, error) {
+	pk, err := secpFactory.RecoverHashPublicKey(submissionHash(chainID, nonce, data), sig)
+	if err != nil {
+		return nil, err
+	}
+	sub := &Submission{
+		Signer: pk.Address(),
+		Nonce:  nonce,
+	}
+	copy(sub.Sig[:], sig)
+	return sub, nil
+}
+
+// IsSigned returns true if the data was signed by its submitter
+func (s *Submission) IsSigned() bool {
+	return s.Signer != ids.ShortEmpty
+}
+
+// Verify returns nil iff this submission is signed by its submitter for
+// [data] on the chain [chainID]
+func (s *Submission) Verify(chainID ids.ID, data []byte) error {
+	pk, err := secpFactory.RecoverHashPublicKey(submissionHash(chainID, s.Nonce, data), s.Sig[:])
+	if err != nil {
+		return err
+	}
+	if pk.Address() != s.Signer {
+		return errWrongSigner
+	}
+	return nil
+}
+
+// submissionHash returns the hash signed by the submitter of [data] to the
+// chain [chainID] with [nonce]
+func submissionHash(chainID ids.ID, nonce uint64, data []byte) []byte {
+	bytes := make([]byte, 0, len(chainID)+database.Uint64Size+len(data))
+	bytes = append(bytes, chainID[:]...)
+	bytes = append(bytes, database.PackUInt64(nonce)...)
+	bytes = append(bytes, data...)
+	return hashing.ComputeHash256(bytes)
+}
+
+// nonceKey identifies a nonce of a submitter
+type nonceKey struct {
+	signer ids.ShortID
+	nonce  uint64
+}
+
+// isNonceUsed returns true if [nonce] of [signer] was used by the block
+// [blkID] or by one of its ancestors.
+// Returns [errNoncesMissing] until the nonces of the blocks missing after
+// state syncing are indexed, as they may have been used by those blocks.
+func (vm *VM) isNonceUsed(blkID ids.ID, signer ids.ShortID, nonce uint64) (bool, error) {
+	// The nonces of processing blocks aren't indexed yet
+	for {
+		blk, err := vm.getBlock(blkID)
+		if err != nil {
+			return false, err
+		}
+		if blk.Status() == choices.Accepted {
+			break
+		}
+		for _, sub := range blk.Sbmsns {
+			if sub.Signer == signer && sub.Nonce == nonce {
+				return true, nil
+			}
+		}
+		blkID = blk.Parent()
+	}
+	noncesMissing, err := vm.state.AreNoncesMissing()
+	if err != nil {
+		return false, err
+	}
+	if noncesMissing {
+		return false, errNoncesMissing
+	}
+	return vm.state.IsNonceUsed(signer, nonce)
+}
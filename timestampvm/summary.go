@@ -0,0 +1,101 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/snow/engine/snowman/block"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+)
+
+var _ block.StateSummary = &Summary{}
+
+// Summary is a state summary of the chain at an accepted block.
+// Syncing to it makes that block the last accepted block, and the blocks
+// before it are then fetched from peers.
+type Summary struct {
+	Blk []byte      `serialize:"true"` // Bytes of the accepted block
+	Acc Accumulator `serialize:"true"` // Accumulator after the parent of the accepted block
+
+	id    ids.ID // hold this summary's ID
+	bytes []byte // this summary's encoded bytes
+	blk   *Block // the accepted block
+	vm    *VM    // the underlying VM reference
+}
+
+// newSummary returns the summary of the chain at the accepted block [blk]
+func newSummary(blk *Block, vm *VM) (*Summary, error) {
+	size, err := vm.state.GetAccumulatorSizeAtHeight(blk.Height() - 1)
+	if err != nil {
+		return nil, err
+	}
+	acc, err := vm.state.GetAccumulator(size)
+	if err != nil {
+		return nil, err
+	}
+	summary := &Summary{
+		Blk: blk.Bytes(),
+		Acc: *acc,
+	}
+	bytes, err := Codec.Marshal(CodecVersion, summary)
+	if err != nil {
+		return nil, err
+	}
+	summary.initialize(bytes, blk, vm)
+	return summary, nil
+}
+
+// parseSummary unmarshals [bytes] into a summary and initializes it with the
+// block it holds and [vm]
+func parseSummary(bytes []byte, vm *VM) (*Summary, error) {
+	summary := &Summary{}
+	if _, err := Codec.Unmarshal(bytes, summary); err != nil {
+		return nil, err
+	}
+	blk, err := parseBlock(summary.Blk, choices.Processing, vm)
+	if err != nil {
+		return nil, err
+	}
+	// Ensure the block has the format scheduled at its timestamp
+	if err := blk.verifyVersion(); err != nil {
+		return nil, err
+	}
+	// Ensure the block commits to the accumulator of the summary
+	if err := summary.Acc.verify(); err != nil {
+		return nil, err
+	}
+	blk.acc = summary.Acc.with(blk.Entries())
+	if err := blk.verifyRoot(); err != nil {
+		return nil, err
+	}
+	summary.initialize(bytes, blk, vm)
+	return summary, nil
+}
+
+// initialize sets [s.bytes] to [bytes], [s.id] to hash([s.bytes]), [s.blk]
+// to [blk] and [s.vm] to [vm]
+func (s *Summary) initialize(bytes []byte, blk *Block, vm *VM) {
+	s.bytes = bytes
+	s.id = hashing.ComputeHash256Array(s.bytes)
+	s.blk = blk
+	s.vm = vm
+}
+
+// ID returns the ID of this summary
+func (s *Summary) ID() ids.ID { return s.id }
+
+// Height returns the height of the block this summary is made at
+func (s *Summary) Height() uint64 { return s.blk.Height() }
+
+// Bytes returns the byte repr. of this summary
+func (s *Summary) Bytes() []byte { return s.bytes }
+
+// Accept syncs this node to this summary, unless its last accepted block is
+// recent enough to bootstrap the blocks up to the summary instead
+func (s *Summary) Accept(ctx context.Context) (block.StateSyncMode, error) {
+	return s.vm.acceptSummary(ctx, s)
+}
@@ -0,0 +1,378 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/ava-labs/avalanchego/database"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow/choices"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/avalanchego/utils/units"
+)
+
+const (
+	// maximum number of blocks requested from, or sent to, a peer at once
+	maxSyncBatchSize = 1024
+	// maximum total length of the blocks sent in a single response, which is
+	// marshalled with [syncCodec] as it's larger than [Codec] allows
+	maxSyncBatchBytes = units.MiB
+	// how often missing blocks are requested if no request is outstanding
+	syncFrequency = 250 * time.Millisecond
+)
+
+var (
+	errNoBlocks        = errors.New("response holds no blocks")
+	errTooManyBlocks   = errors.New("response holds too many blocks")
+	errUnexpectedBlock = errors.New("response holds an unexpected block")
+
+	_ RequestHandler = &blockSyncer{}
+)
+
+// blockSyncer fetches from peers the accepted blocks that are missing after
+// state syncing, from the most recent to the genesis block, and serves the
+// accepted blocks of this node to the peers doing the same.
+type blockSyncer struct {
+	vm        *VM
+	appSender common.AppSender
+
+	// connected nodes that missing blocks can be requested from
+	peers set.Set[ids.NodeID]
+
+	// outstanding request for missing blocks, if any
+	requesting     bool
+	requestNodeID  ids.NodeID
+	requestID      uint32
+	requestedBlkID ids.ID
+
+	// whether the sync loop is running
+	running bool
+
+	shutdownChan chan struct{}
+}
+
+func newBlockSyncer(vm *VM, appSender common.AppSender) *blockSyncer {
+	return &blockSyncer{
+		vm:           vm,
+		appSender:    appSender,
+		shutdownChan: make(chan struct{}),
+	}
+}
+
+// start runs the sync loop, unless it's running already
+func (s *blockSyncer) start() {
+	if s.running {
+		return
+	}
+	s.running = true
+	go s.run()
+}
+
+// run periodically requests the missing blocks until none is missing or
+// [shutdown] is called.
+func (s *blockSyncer) run() {
+	ticker := time.NewTicker(syncFrequency)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			s.vm.snowCtx.Lock.Lock()
+			done := s.sync(context.TODO())
+			if done {
+				s.running = false
+			}
+			s.vm.snowCtx.Lock.Unlock()
+			if done {
+				return
+			}
+		case <-s.shutdownChan:
+			return
+		}
+	}
+}
+
+// shutdown stops the sync loop
+func (s *blockSyncer) shutdown() {
+	close(s.shutdownChan)
+}
+
+// sync indexes the missing blocks this node has stored already, and requests
+// the next missing block it hasn't stored from a peer.
+// Returns true once no block is missing.
+func (s *blockSyncer) sync(ctx context.Context) bool {
+	if s.requesting {
+		return false
+	}
+
+	blkID, err := s.vm.state.GetMissingBlockID()
+	if err == database.ErrNotFound {
+		return true
+	}
+	if err != nil {
+		s.vm.snowCtx.Log.Warn("failed to get missing block ID", zap.Error(err))
+		return false
+	}
+
+	// The missing blocks may have been accepted by this node before syncing
+	blks := make([]*Block, 0, maxSyncBatchSize)
+	for len(blks) < maxSyncBatchSize {
+		blk, err := s.vm.state.GetBlock(blkID)
+		if err != nil {
+			break
+		}
+		blks = append(blks, blk)
+		if blk.Height() == 0 {
+			break
+		}
+		blkID = blk.Parent()
+	}
+	if len(blks) > 0 {
+		done, err := s.indexBlocks(blks)
+		if err != nil {
+			s.vm.snowCtx.Log.Warn("failed to index stored blocks", zap.Error(err))
+		}
+		// Further stored blocks are indexed by the next sync
+		return done
+	}
+
+	s.request(ctx, blkID)
+	return false
+}
+
+// request asks a peer for the block [blkID] and its ancestors
+func (s *blockSyncer) request(ctx context.Context, blkID ids.ID) {
+	nodeID, ok := s.peers.Peek()
+	if !ok {
+		s.vm.snowCtx.Log.Debug("no peer to request missing blocks from")
+		return
+	}
+
+	msgBytes, err := BuildRequest(&BlocksRequest{
+		BlkID:     blkID,
+		MaxBlocks: maxSyncBatchSize,
+	})
+	if err != nil {
+		s.vm.snowCtx.Log.Warn("failed to build blocks request", zap.Error(err))
+		return
+	}
+
+	s.requestID++
+	nodeIDs := set.NewSet[ids.NodeID](1)
+	nodeIDs.Add(nodeID)
+	if err := s.appSender.SendAppRequest(ctx, nodeIDs, s.requestID, msgBytes); err != nil {
+		s.vm.snowCtx.Log.Warn("failed to send blocks request", zap.Error(err))
+		return
+	}
+	s.requesting = true
+	s.requestNodeID = nodeID
+	s.requestedBlkID = blkID
+}
+
+// handleResponse stores and indexes the missing blocks sent by [nodeID] in
+// response to the outstanding request, and requests the next missing blocks.
+// Invalid responses are dropped, so the blocks are requested again.
+func (s *blockSyncer) handleResponse(ctx context.Context, nodeID ids.NodeID, requestID uint32, responseBytes []byte) error {
+	if !s.requesting || nodeID != s.requestNodeID || requestID != s.requestID {
+		s.vm.snowCtx.Log.Debug("dropping unexpected blocks response",
+			zap.Stringer("nodeID", nodeID),
+			zap.Uint32("requestID", requestID),
+		)
+		return nil
+	}
+	s.requesting = false
+
+	blks, err := s.parseResponse(responseBytes)
+	if err != nil {
+		s.vm.snowCtx.Log.Debug("dropping invalid blocks response",
+			zap.Stringer("nodeID", nodeID),
+			zap.Error(err),
+		)
+		return nil
+	}
+	done, err := s.indexBlocks(blks)
+	if err != nil || done {
+		return err
+	}
+	s.sync(ctx)
+	return nil
+}
+
+// requestFailed allows the missing blocks to be requested again once the
+// outstanding request failed
+func (s *blockSyncer) requestFailed(nodeID ids.NodeID, requestID uint32) {
+	if s.requesting && nodeID == s.requestNodeID && requestID == s.requestID {
+		s.requesting = false
+	}
+}
+
+// parseResponse parses the blocks in [responseBytes] and ensures they are
+// the requested block followed by its ancestors
+func (s *blockSyncer) parseResponse(responseBytes []byte) ([]*Block, error) {
+	resp := BlocksResponse{}
+	if _, err := syncCodec.Unmarshal(responseBytes, &resp); err != nil {
+		return nil, err
+	}
+	switch {
+	case len(resp.Blks) == 0:
+		return nil, errNoBlocks
+	case len(resp.Blks) > maxSyncBatchSize:
+		return nil, errTooManyBlocks
+	}
+
+	blks := make([]*Block, len(resp.Blks))
+	expectedID := s.requestedBlkID
+	for i, blkBytes := range resp.Blks {
+		blk, err := parseBlock(blkBytes, choices.Processing, s.vm)
+		if err != nil {
+			return nil, err
+		}
+		// The genesis block has no ancestors
+		if blk.ID() != expectedID || (i > 0 && blks[i-1].Height() == 0) {
+			return nil, errUnexpectedBlock
+		}
+		blks[i] = blk
+		expectedID = blk.Parent()
+	}
+	return blks, nil
+}
+
+// indexBlocks stores [blks] as accepted, unless they are already, and indexes
+// them by height, by the data they hold and by timestamp, and records the
+// nonces they use.
+// [blks] must be a block followed by its ancestors.
+// Returns true once no block is missing.
+func (s *blockSyncer) indexBlocks(blks []*Block) (bool, error) {
+	for _, blk := range blks {
+		if blk.Status() != choices.Accepted {
+			blk.SetStatus(choices.Accepted)
+			if err := s.vm.state.PutBlock(blk); err != nil {
+				return false, err
+			}
+		}
+		if err := s.vm.state.SetBlockIDAtHeight(blk.Height(), blk.ID()); err != nil {
+			return false, err
+		}
+		if err := s.vm.state.IndexData(blk); err != nil {
+			return false, err
+		}
+		if err := s.vm.state.IndexTimestamp(blk); err != nil {
+			return false, err
+		}
+		if err := s.vm.state.IndexNonces(blk); err != nil {
+			return false, err
+		}
+	}
+
+	oldestBlk := blks[len(blks)-1]
+	done := oldestBlk.Height() == 0
+	if done {
+		if err := s.vm.state.DeleteMissingBlockID(); err != nil {
+			return false, err
+		}
+	} else if err := s.vm.state.SetMissingBlockID(oldestBlk.Parent()); err != nil {
+		return false, err
+	}
+
+	// The blocks before signed data was active use no nonces
+	noncesMissing, err := s.vm.state.AreNoncesMissing()
+	if err != nil {
+		return false, err
+	}
+	noncesIndexed := noncesMissing && (done || oldestBlk.version < BlockVersion4)
+	if noncesIndexed {
+		if err := s.vm.state.DeleteNoncesMissing(); err != nil {
+			return false, err
+		}
+	}
+
+	// Commit changes to database
+	if err := s.vm.state.Commit(); err != nil {
+		return false, err
+	}
+	if noncesIndexed {
+		s.notifyNoncesIndexed()
+	}
+	return done, nil
+}
+
+// notifyNoncesIndexed lets the engine, waiting for the nonces of the missing
+// blocks to be indexed, verify new blocks.
+// The engine is notified asynchronously, as it may be waiting for the
+// context lock held by the caller.
+func (s *blockSyncer) notifyNoncesIndexed() {
+	s.vm.snowCtx.Log.Info("indexed the nonces of the missing blocks")
+	go func() {
+		select {
+		case s.vm.toEngine <- common.StateSyncDone:
+		case <-s.shutdownChan:
+		}
+	}()
+}
+
+// HandleBlocksRequest sends to [nodeID] the requested block and as many of
+// its ancestors as fit in a response.
+// If the requested block isn't accepted, the response holds no blocks.
+func (s *blockSyncer) HandleBlocksRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, req *BlocksRequest) error {
+	maxBlocks := int(req.MaxBlocks)
+	if maxBlocks > maxSyncBatchSize {
+		maxBlocks = maxSyncBatchSize
+	}
+
+	var (
+		blks  = make([][]byte, 0, maxBlocks)
+		size  = 0
+		blkID = req.BlkID
+	)
+	for len(blks) < maxBlocks {
+		blk, err := s.vm.state.GetBlock(blkID)
+		if err != nil || blk.Status() != choices.Accepted {
+			break
+		}
+		// A response always holds the requested block, if it's accepted
+		size += len(blk.Bytes())
+		if len(blks) > 0 && size > maxSyncBatchBytes {
+			break
+		}
+		blks = append(blks, blk.Bytes())
+		if blk.Height() == 0 {
+			break
+		}
+		blkID = blk.Parent()
+	}
+
+	responseBytes, err := syncCodec.Marshal(CodecVersion, &BlocksResponse{Blks: blks})
+	if err != nil {
+		// Answer with no blocks rather than not at all, so the peer requests
+		// the blocks again without waiting for the request to time out
+		s.vm.snowCtx.Log.Warn("failed to build blocks response", zap.Error(err))
+		responseBytes, err = syncCodec.Marshal(CodecVersion, &BlocksResponse{})
+		if err != nil {
+			s.vm.snowCtx.Log.Warn("failed to build empty blocks response", zap.Error(err))
+			return nil
+		}
+	}
+	if err := s.appSender.SendAppResponse(ctx, nodeID, requestID, responseBytes); err != nil {
+		s.vm.snowCtx.Log.Warn("failed to send blocks response", zap.Error(err))
+	}
+	return nil
+}
+
+// connected allows missing blocks to be requested from [nodeID]
+func (s *blockSyncer) connected(nodeID ids.NodeID) {
+	if nodeID != s.vm.snowCtx.NodeID {
+		s.peers.Add(nodeID)
+	}
+}
+
+// disconnected stops requesting missing blocks from [nodeID]
+func (s *blockSyncer) disconnected(nodeID ids.NodeID) {
+	s.peers.Remove(nodeID)
+}
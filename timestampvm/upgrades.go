@@ -0,0 +1,155 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// Names of the network upgrades
+const (
+	// MultiEntryUpgrade switches to [BlockVersion1] blocks
+	MultiEntryUpgrade = "multiEntry"
+	// VariableLengthDataUpgrade switches to [BlockVersion2] blocks
+	VariableLengthDataUpgrade = "variableLengthData"
+	// MerkleRootUpgrade switches to [BlockVersion3] blocks
+	MerkleRootUpgrade = "merkleRoot"
+	// SignedDataUpgrade switches to [BlockVersion4] blocks
+	SignedDataUpgrade = "signedData"
+	// MillisecondTimestampUpgrade switches to [BlockVersion5] blocks
+	MillisecondTimestampUpgrade = "millisecondTimestamp"
+)
+
+var (
+	errUnknownUpgrade         = errors.New("unknown upgrade")
+	errDuplicateUpgrade       = errors.New("upgrade is scheduled more than once")
+	errNegativeActivationTime = errors.New("upgrade activation time can't be negative")
+	errUpgradeOutOfOrder      = errors.New("upgrade can't activate before the upgrades preceding it")
+	errPrecedingUnscheduled   = errors.New("upgrade can't be scheduled unless the upgrades preceding it are")
+
+	// The known network upgrades, in the order they must activate in, with
+	// the block version each of them switches to
+	knownUpgrades = []struct {
+		name         string
+		blockVersion uint16
+	}{
+		{name: MultiEntryUpgrade, blockVersion: BlockVersion1},
+		{name: VariableLengthDataUpgrade, blockVersion: BlockVersion2},
+		{name: MerkleRootUpgrade, blockVersion: BlockVersion3},
+		{name: SignedDataUpgrade, blockVersion: BlockVersion4},
+		{name: MillisecondTimestampUpgrade, blockVersion: BlockVersion5},
+	}
+)
+
+// Upgrade schedules the activation of the network upgrade [Name] at
+// [Timestamp], in Unix seconds
+type Upgrade struct {
+	Name      string `json:"name"`
+	Timestamp int64  `json:"timestamp"`
+}
+
+// Upgrades is the activation schedule of the network upgrades
+type Upgrades struct {
+	// upgrade name --> activation time, for the scheduled upgrades
+	activations map[string]time.Time
+}
+
+// ParseUpgrades parses [upgradeBytes], a JSON list of [Upgrade]s, into the
+// activation schedule of the network upgrades.
+// Upgrades missing from [upgradeBytes] never activate, so a node started
+// without upgrades keeps building the blocks of the chains that predate them.
+// New chains must schedule the upgrades they want, at 0 to activate them from
+// genesis.
+func ParseUpgrades(upgradeBytes []byte) (*Upgrades, error) {
+	var upgrades []Upgrade
+	if len(upgradeBytes) > 0 {
+		if err := json.Unmarshal(upgradeBytes, &upgrades); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal upgrades: %w", err)
+		}
+	}
+
+	scheduled := make(map[string]time.Time, len(upgrades))
+	for _, upgrade := range upgrades {
+		if !isKnownUpgrade(upgrade.Name) {
+			return nil, fmt.Errorf("%w: %q", errUnknownUpgrade, upgrade.Name)
+		}
+		if _, ok := scheduled[upgrade.Name]; ok {
+			return nil, fmt.Errorf("%w: %q", errDuplicateUpgrade, upgrade.Name)
+		}
+		if upgrade.Timestamp < 0 {
+			return nil, fmt.Errorf("%w: %q", errNegativeActivationTime, upgrade.Name)
+		}
+		scheduled[upgrade.Name] = time.Unix(upgrade.Timestamp, 0)
+	}
+
+	u := &Upgrades{
+		activations: make(map[string]time.Time, len(knownUpgrades)),
+	}
+	var (
+		previous    time.Time
+		unscheduled bool
+	)
+	for _, known := range knownUpgrades {
+		activation, ok := scheduled[known.name]
+		switch {
+		case !ok:
+			unscheduled = true
+			continue
+		case unscheduled:
+			return nil, fmt.Errorf("%w: %q", errPrecedingUnscheduled, known.name)
+		case activation.Before(previous):
+			return nil, fmt.Errorf("%w: %q", errUpgradeOutOfOrder, known.name)
+		}
+		u.activations[known.name] = activation
+		previous = activation
+	}
+	return u, nil
+}
+
+// IsActivated returns true if the upgrade [name] is active at [timestamp].
+// Upgrades that aren't scheduled are never active.
+func (u *Upgrades) IsActivated(name string, timestamp time.Time) bool {
+	activation, ok := u.activations[name]
+	return ok && !timestamp.Before(activation)
+}
+
+// BlockVersion returns the version of the blocks with [timestamp]
+func (u *Upgrades) BlockVersion(timestamp time.Time) uint16 {
+	version := uint16(BlockVersion0)
+	for _, known := range knownUpgrades {
+		if !u.IsActivated(known.name, timestamp) {
+			break
+		}
+		version = known.blockVersion
+	}
+	return version
+}
+
+// Schedule returns the activation schedule of the scheduled upgrades
+func (u *Upgrades) Schedule() []Upgrade {
+	schedule := make([]Upgrade, 0, len(u.activations))
+	for _, known := range knownUpgrades {
+		activation, ok := u.activations[known.name]
+		if !ok {
+			break
+		}
+		schedule = append(schedule, Upgrade{
+			Name:      known.name,
+			Timestamp: activation.Unix(),
+		})
+	}
+	return schedule
+}
+
+func isKnownUpgrade(name string) bool {
+	for _, known := range knownUpgrades {
+		if known.name == name {
+			return true
+		}
+	}
+	return false
+}
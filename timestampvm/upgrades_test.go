@@ -0,0 +1,87 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseUpgrades(t *testing.T) {
+	tests := []struct {
+		name         string
+		upgradeBytes []byte
+		expectedErr  error
+	}{
+		{
+			name:         "empty",
+			upgradeBytes: nil,
+		},
+		{
+			name:         "scheduled",
+			upgradeBytes: []byte(`[{"name":"multiEntry","timestamp":100},{"name":"variableLengthData","timestamp":200},{"name":"merkleRoot","timestamp":300},{"name":"signedData","timestamp":400},{"name":"millisecondTimestamp","timestamp":500}]`),
+		},
+		{
+			name:         "unknown upgrade",
+			upgradeBytes: []byte(`[{"name":"unknown","timestamp":100}]`),
+			expectedErr:  errUnknownUpgrade,
+		},
+		{
+			name:         "duplicate upgrade",
+			upgradeBytes: []byte(`[{"name":"multiEntry","timestamp":100},{"name":"multiEntry","timestamp":200}]`),
+			expectedErr:  errDuplicateUpgrade,
+		},
+		{
+			name:         "negative activation time",
+			upgradeBytes: []byte(`[{"name":"multiEntry","timestamp":-1}]`),
+			expectedErr:  errNegativeActivationTime,
+		},
+		{
+			name:         "out of order",
+			upgradeBytes: []byte(`[{"name":"multiEntry","timestamp":200},{"name":"variableLengthData","timestamp":100}]`),
+			expectedErr:  errUpgradeOutOfOrder,
+		},
+		{
+			name:         "preceding upgrade unscheduled",
+			upgradeBytes: []byte(`[{"name":"variableLengthData","timestamp":100}]`),
+			expectedErr:  errPrecedingUnscheduled,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ParseUpgrades(test.upgradeBytes)
+			require.ErrorIs(t, err, test.expectedErr)
+		})
+	}
+}
+
+func TestUpgradesBlockVersion(t *testing.T) {
+	require := require.New(t)
+
+	// upgrades that aren't scheduled are never active
+	upgrades, err := ParseUpgrades(nil)
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion0), upgrades.BlockVersion(time.Unix(0, 0)))
+	require.Equal(uint16(BlockVersion0), upgrades.BlockVersion(time.Unix(1<<40, 0)))
+	require.Empty(upgrades.Schedule())
+
+	upgrades, err = ParseUpgrades([]byte(`[{"name":"multiEntry","timestamp":0}]`))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion1), upgrades.BlockVersion(time.Unix(1<<40, 0)))
+	require.Equal([]Upgrade{{Name: MultiEntryUpgrade, Timestamp: 0}}, upgrades.Schedule())
+
+	upgrades, err = ParseUpgrades([]byte(`[{"name":"multiEntry","timestamp":100},{"name":"variableLengthData","timestamp":200},{"name":"merkleRoot","timestamp":300},{"name":"signedData","timestamp":400},{"name":"millisecondTimestamp","timestamp":500}]`))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion0), upgrades.BlockVersion(time.Unix(99, 0)))
+	require.Equal(uint16(BlockVersion1), upgrades.BlockVersion(time.Unix(100, 0)))
+	require.Equal(uint16(BlockVersion1), upgrades.BlockVersion(time.Unix(199, 0)))
+	require.Equal(uint16(BlockVersion2), upgrades.BlockVersion(time.Unix(200, 0)))
+	require.Equal(uint16(BlockVersion3), upgrades.BlockVersion(time.Unix(300, 0)))
+	require.Equal(uint16(BlockVersion4), upgrades.BlockVersion(time.Unix(400, 0)))
+	require.Equal(uint16(BlockVersion5), upgrades.BlockVersion(time.Unix(500, 0)))
+	require.True(upgrades.IsActivated(MultiEntryUpgrade, time.Unix(150, 0)))
+	require.False(upgrades.IsActivated(VariableLengthDataUpgrade, time.Unix(150, 0)))
+}
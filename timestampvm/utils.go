@@ -3,6 +3,11 @@
 
 package timestampvm
 
+import (
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+)
+
 // BytesToData converts a byte slice to an array. If the byte slice input is
 // larger than [DataLen], it will be truncated.
 func BytesToData(input []byte) [DataLen]byte {
@@ -14,3 +19,9 @@ func BytesToData(input []byte) [DataLen]byte {
 	copy(data[:], input[:lim])
 	return data
 }
+
+// dataID returns the ID of [data], used to index data in the mempool and to
+// remember gossiped data
+func dataID(data []byte) ids.ID {
+	return hashing.ComputeHash256Array(data)
+}
@@ -7,12 +7,16 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"time"
 
 	"github.com/gorilla/rpc/v2"
 	log "github.com/inconshreveable/log15"
+	"github.com/prometheus/client_golang/prometheus"
 	"go.uber.org/zap"
 
+	"github.com/ava-labs/avalanchego/cache"
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/database/manager"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow"
@@ -22,25 +26,35 @@ import (
 	"github.com/ava-labs/avalanchego/snow/engine/snowman/block"
 	"github.com/ava-labs/avalanchego/utils"
 	"github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/avalanchego/utils/set"
 	"github.com/ava-labs/avalanchego/version"
 )
 
 const (
-	DataLen        = 32
-	Name           = "timestampvm"
-	MaxMempoolSize = 4096
+	DataLen = 32
+	Name    = "timestampvm"
+
+	// number of blocks processed between commits while adding the blocks
+	// accepted before the accumulator or an index existed to them
+	backfillCommitInterval = 1024
 )
 
 var (
 	errNoPendingBlocks = errors.New("there is no block to propose")
-	errBadGenesisBytes = errors.New("genesis data should be bytes (max length 32)")
+	errAlreadyAccepted = errors.New("data is already accepted")
+	errFixedDataLen    = fmt.Errorf("data must be %d bytes long until the %s upgrade", DataLen, VariableLengthDataUpgrade)
+	errUnsignedOnly    = fmt.Errorf("data can't be signed until the %s upgrade", SignedDataUpgrade)
+	errBadGenesisBytes = fmt.Errorf("genesis data should be bytes (max length %d)", MaxDataLen)
 	Version            = &version.Semantic{
 		Major: 1,
 		Minor: 3,
 		Patch: 3,
 	}
 
-	_ block.ChainVM = &VM{}
+	_ block.ChainVM              = &VM{}
+	_ block.HeightIndexedChainVM = &VM{}
+	_ block.StateSyncableVM      = &VM{}
 )
 
 // VM implements the snowman.VM interface
@@ -51,6 +65,12 @@ type VM struct {
 	snowCtx   *snow.Context
 	dbManager manager.Manager
 
+	// Chain config of this vm
+	config Config
+
+	// Activation schedule of the network upgrades
+	upgrades *Upgrades
+
 	// State of this VM
 	state State
 
@@ -61,15 +81,42 @@ type VM struct {
 	toEngine chan<- common.Message
 
 	// Proposed pieces of data that haven't been put into a block and proposed yet
-	mempool [][DataLen]byte
+	mempool *Mempool
+
+	// Propagates proposed data to the other nodes of the network
+	gossiper *gossiper
+
+	// Fetches the blocks missing after state syncing from the other nodes of
+	// the network
+	syncer *blockSyncer
+
+	// Answers the requests of the other chains of this node
+	crossChain *crossChainHandler
+
+	// Pushes the decided blocks to the subscribers of the events WebSocket
+	events *eventHub
+
+	// Warp message ID --> Signature of this node over the message
+	warpSignatures cache.Cacher[ids.ID, []byte]
+
+	// Why the recently dropped proposals were dropped
+	dropped *droppedProposals
+
+	// Metrics of this VM, served by the node
+	metrics *metrics
 
 	// Block ID --> Block
 	// Each element is a block that passed verification but
 	// hasn't yet been accepted/rejected
 	verifiedBlocks map[ids.ID]*Block
+	// Data ID --> IDs of the verified blocks holding the data
+	verifiedData map[ids.ID]set.Set[ids.ID]
 
 	// Indicates that this VM has finised bootstrapping for the chain
 	bootstrapped utils.Atomic[bool]
+
+	// Indicates that an operator paused block building
+	buildingPaused utils.Atomic[bool]
 }
 
 // Initialize this vm
@@ -85,11 +132,11 @@ func (vm *VM) Initialize(
 	snowCtx *snow.Context,
 	dbManager manager.Manager,
 	genesisData []byte,
-	_ []byte,
-	_ []byte,
+	upgradeBytes []byte,
+	configBytes []byte,
 	toEngine chan<- common.Message,
 	_ []*common.Fx,
-	_ common.AppSender,
+	appSender common.AppSender,
 ) error {
 	version, err := vm.Version(ctx)
 	if err != nil {
@@ -98,10 +145,49 @@ func (vm *VM) Initialize(
 	}
 	log.Info("Initializing Timestamp VM", "Version", version)
 
+	vm.config, err = ParseConfig(configBytes)
+	if err != nil {
+		log.Error("error parsing Timestamp VM config", "err", err)
+		return err
+	}
+	if err := setLogLevel(snowCtx.Log, vm.config.LogLevel); err != nil {
+		return err
+	}
+	snowCtx.Log.Info("loaded Timestamp VM config",
+		zap.Reflect("config", vm.config),
+	)
+
+	vm.upgrades, err = ParseUpgrades(upgradeBytes)
+	if err != nil {
+		log.Error("error parsing Timestamp VM upgrades", "err", err)
+		return err
+	}
+	snowCtx.Log.Info("loaded Timestamp VM upgrades",
+		zap.Reflect("upgrades", vm.upgrades.Schedule()),
+	)
+
 	vm.dbManager = dbManager
 	vm.snowCtx = snowCtx
 	vm.toEngine = toEngine
 	vm.verifiedBlocks = make(map[ids.ID]*Block)
+	vm.verifiedData = make(map[ids.ID]set.Set[ids.ID])
+	vm.mempool = NewMempool(vm.config.MempoolSize, vm.config.MempoolEvictionPolicy)
+	vm.gossiper = newGossiper(vm, appSender)
+	vm.syncer = newBlockSyncer(vm, appSender)
+	vm.crossChain = newCrossChainHandler(vm, appSender)
+	vm.events = newEventHub()
+	vm.warpSignatures = &cache.LRU[ids.ID, []byte]{Size: warpSignatureCacheSize}
+
+	// Serve the metrics of this VM along with the node's
+	registry := prometheus.NewRegistry()
+	vm.metrics, err = newMetrics(vm, registry)
+	if err != nil {
+		return err
+	}
+	if err := snowCtx.Metrics.Register(registry); err != nil {
+		return err
+	}
+	vm.dropped = newDroppedProposals(maxDroppedProposals, vm.metrics.proposalsDropped)
 
 	// Create new state
 	vm.state = NewState(vm.dbManager.Current().Database, vm)
@@ -111,6 +197,31 @@ func (vm *VM) Initialize(
 		return err
 	}
 
+	// Index the blocks accepted before the height index existed
+	if err := vm.initHeightIndex(); err != nil {
+		return err
+	}
+
+	// Index the blocks rejected before rejected blocks were pruned
+	if err := vm.initRejectedIndex(); err != nil {
+		return err
+	}
+
+	// Add the data accepted before the accumulator existed to it
+	if err := vm.initAccumulator(); err != nil {
+		return err
+	}
+
+	// Index the data accepted before the data index existed
+	if err := vm.initDataIndex(); err != nil {
+		return err
+	}
+
+	// Index the blocks accepted before the time index existed
+	if err := vm.initTimeIndex(); err != nil {
+		return err
+	}
+
 	// Get last accepted
 	lastAccepted, err := vm.state.GetLastAccepted()
 	if err != nil {
@@ -121,10 +232,45 @@ func (vm *VM) Initialize(
 		zap.Any("id", lastAccepted),
 	)
 
+	// Start gossiping proposed data to the network
+	go vm.gossiper.start()
+
+	// Resume fetching the blocks missing after state syncing, if any
+	vm.syncer.start()
+
 	// Build off the most recently accepted block
 	return vm.SetPreference(ctx, lastAccepted)
 }
 
+// setLogLevel sets the level of the VM's logs to [level], a level name of
+// avalanchego, both for [logger] and for the root log15 logger
+func setLogLevel(logger logging.Logger, level string) error {
+	lvl, err := logging.ToLevel(level)
+	if err != nil {
+		return err
+	}
+	logger.SetLevel(lvl)
+
+	// log15 has no level above crit nor below debug
+	handler := log.StreamHandler(os.Stderr, log.TerminalFormat())
+	switch {
+	case lvl == logging.Off:
+		handler = log.DiscardHandler()
+	case lvl >= logging.Fatal:
+		handler = log.LvlFilterHandler(log.LvlCrit, handler)
+	case lvl >= logging.Error:
+		handler = log.LvlFilterHandler(log.LvlError, handler)
+	case lvl >= logging.Warn:
+		handler = log.LvlFilterHandler(log.LvlWarn, handler)
+	case lvl >= logging.Info:
+		handler = log.LvlFilterHandler(log.LvlInfo, handler)
+	default:
+		handler = log.LvlFilterHandler(log.LvlDebug, handler)
+	}
+	log.Root().SetHandler(handler)
+	return nil
+}
+
 // Initializes Genesis if required
 func (vm *VM) initGenesis(genesisData []byte) error {
 	stateInitialized, err := vm.state.IsInitialized()
@@ -137,18 +283,13 @@ func (vm *VM) initGenesis(genesisData []byte) error {
 		return nil
 	}
 
-	if len(genesisData) > DataLen {
+	if len(genesisData) > MaxDataLen {
 		return errBadGenesisBytes
 	}
-
-	// genesisData is a byte slice but each block contains an byte array
-	// Take the first [DataLen] bytes from genesisData and put them in an array
-	genesisDataArr := BytesToData(genesisData)
-	log.Debug("genesis", "data", genesisDataArr)
+	log.Debug("genesis", "data", genesisData)
 
 	// Create the genesis block
-	// Timestamp of genesis block is 0. It has no parent.
-	genesisBlock, err := vm.NewBlock(ids.Empty, 0, genesisDataArr, time.Unix(0, 0))
+	genesisBlock, err := vm.newGenesisBlock(genesisData)
 	if err != nil {
 		log.Error("error while creating genesis block: %v", err)
 		return err
@@ -175,23 +316,282 @@ func (vm *VM) initGenesis(genesisData []byte) error {
 	return vm.state.Commit()
 }
 
+// Indexes by height the accepted blocks of a chain created before the height
+// index existed, if required
+func (vm *VM) initHeightIndex() error {
+	heightIndexed, err := vm.state.IsHeightIndexed()
+	if err != nil {
+		return err
+	}
+
+	// if every accepted block is already indexed, skip indexing.
+	if heightIndexed {
+		return nil
+	}
+
+	// Walk back from the last accepted block to genesis, indexing each block
+	blkID, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return err
+	}
+	for {
+		blk, err := vm.state.GetBlock(blkID)
+		if err != nil {
+			return fmt.Errorf("error while indexing block %s: %w", blkID, err)
+		}
+		if err := vm.state.SetBlockIDAtHeight(blk.Height(), blkID); err != nil {
+			return err
+		}
+		if blk.Height() == 0 {
+			break
+		}
+		blkID = blk.Parent()
+	}
+
+	// Mark this vm's state as height indexed, so we can skip indexing in further restarts
+	if err := vm.state.SetHeightIndexed(); err != nil {
+		return fmt.Errorf("error while setting db to height indexed: %w", err)
+	}
+
+	// Flush VM's database to underlying db
+	return vm.state.Commit()
+}
+
+// Indexes the rejected blocks stored before rejected blocks were pruned, and
+// prunes the ones that are too far behind the last accepted block, if required
+func (vm *VM) initRejectedIndex() error {
+	rejectedIndexed, err := vm.state.IsRejectedIndexed()
+	if err != nil {
+		return err
+	}
+
+	// if every rejected block is already indexed, skip indexing.
+	if rejectedIndexed {
+		return nil
+	}
+
+	if err := vm.state.IndexStoredRejectedBlocks(); err != nil {
+		return fmt.Errorf("error while indexing rejected blocks: %w", err)
+	}
+
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return err
+	}
+	lastAcceptedBlock, err := vm.state.GetBlock(lastAccepted)
+	if err != nil {
+		return err
+	}
+	if err := vm.pruneRejectedBlocks(lastAcceptedBlock.Height()); err != nil {
+		return fmt.Errorf("error while pruning rejected blocks: %w", err)
+	}
+
+	// Mark this vm's state as rejected indexed, so we can skip indexing in further restarts
+	if err := vm.state.SetRejectedIndexed(); err != nil {
+		return fmt.Errorf("error while setting db to rejected indexed: %w", err)
+	}
+
+	// Flush VM's database to underlying db
+	return vm.state.Commit()
+}
+
+// Adds the data of the blocks accepted before the accumulator existed to the
+// accumulator, in the order they were accepted, if required
+func (vm *VM) initAccumulator() error {
+	dataAccumulated, err := vm.state.IsDataAccumulated()
+	if err != nil {
+		return err
+	}
+
+	// if the data of every accepted block is already accumulated, skip
+	// accumulating.
+	if dataAccumulated {
+		return nil
+	}
+
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return err
+	}
+	lastAcceptedBlock, err := vm.state.GetBlock(lastAccepted)
+	if err != nil {
+		return err
+	}
+
+	// Start over from genesis, as a previous run may have been interrupted
+	if err := vm.state.ResetAccumulator(); err != nil {
+		return err
+	}
+	for height := uint64(0); height <= lastAcceptedBlock.Height(); height++ {
+		blkID, err := vm.state.GetBlockIDAtHeight(height)
+		if err != nil {
+			return fmt.Errorf("error while accumulating block at height %d: %w", height, err)
+		}
+		blk, err := vm.state.GetBlock(blkID)
+		if err != nil {
+			return fmt.Errorf("error while accumulating block %s: %w", blkID, err)
+		}
+		if err := vm.state.AppendToAccumulator(height, blk.Entries()); err != nil {
+			return err
+		}
+		// Flush regularly, so the changes of a long chain aren't all held in
+		// memory
+		if height%backfillCommitInterval == 0 {
+			if err := vm.state.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Mark this vm's state as data accumulated, so we can skip accumulating in further restarts
+	if err := vm.state.SetDataAccumulated(); err != nil {
+		return fmt.Errorf("error while setting db to data accumulated: %w", err)
+	}
+
+	// Flush VM's database to underlying db
+	return vm.state.Commit()
+}
+
+// Indexes by their data the blocks accepted before the data index existed,
+// if required
+func (vm *VM) initDataIndex() error {
+	dataIndexed, err := vm.state.IsDataIndexed()
+	if err != nil {
+		return err
+	}
+
+	// if the data of every accepted block is already indexed, skip indexing.
+	if dataIndexed {
+		return nil
+	}
+
+	if err := vm.indexAcceptedBlocks(vm.state.IndexData); err != nil {
+		return fmt.Errorf("error while indexing data: %w", err)
+	}
+
+	// Mark this vm's state as data indexed, so we can skip indexing in further restarts
+	if err := vm.state.SetDataIndexed(); err != nil {
+		return fmt.Errorf("error while setting db to data indexed: %w", err)
+	}
+
+	// Flush VM's database to underlying db
+	return vm.state.Commit()
+}
+
+// Indexes by their timestamp the blocks accepted before the time index
+// existed, if required
+func (vm *VM) initTimeIndex() error {
+	timeIndexed, err := vm.state.IsTimeIndexed()
+	if err != nil {
+		return err
+	}
+
+	// if every accepted block is already indexed, skip indexing.
+	if timeIndexed {
+		return nil
+	}
+
+	if err := vm.indexAcceptedBlocks(vm.state.IndexTimestamp); err != nil {
+		return fmt.Errorf("error while indexing timestamps: %w", err)
+	}
+
+	// Mark this vm's state as time indexed, so we can skip indexing in further restarts
+	if err := vm.state.SetTimeIndexed(); err != nil {
+		return fmt.Errorf("error while setting db to time indexed: %w", err)
+	}
+
+	// Flush VM's database to underlying db
+	return vm.state.Commit()
+}
+
+// indexAcceptedBlocks passes every accepted block, from genesis, to [index].
+// The blocks missing after state syncing are skipped, as they are indexed
+// once fetched.
+func (vm *VM) indexAcceptedBlocks(index func(blk *Block) error) error {
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return err
+	}
+	lastAcceptedBlock, err := vm.state.GetBlock(lastAccepted)
+	if err != nil {
+		return err
+	}
+	for height := uint64(0); height <= lastAcceptedBlock.Height(); height++ {
+		blkID, err := vm.state.GetBlockIDAtHeight(height)
+		if err == database.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't get block at height %d: %w", height, err)
+		}
+		blk, err := vm.state.GetBlock(blkID)
+		if err != nil {
+			return fmt.Errorf("couldn't get block %s: %w", blkID, err)
+		}
+		if err := index(blk); err != nil {
+			return err
+		}
+		// Flush regularly, so the changes of a long chain aren't all held in
+		// memory
+		if height%backfillCommitInterval == 0 {
+			if err := vm.state.Commit(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// pruneRejectedBlocks deletes the rejected blocks more than the configured
+// rejected block retention behind [lastAcceptedHeight]
+func (vm *VM) pruneRejectedBlocks(lastAcceptedHeight uint64) error {
+	if lastAcceptedHeight <= vm.config.RejectedBlockRetention {
+		return nil
+	}
+	return vm.state.PruneRejectedBlocks(lastAcceptedHeight - vm.config.RejectedBlockRetention - 1)
+}
+
 // CreateHandlers returns a map where:
-// Keys: The path extension for this VM's API (empty in this case)
+// Keys: The path extension for this VM's API (empty for the JSON-RPC API,
+// [EventsEndpoint] for the WebSocket pushing block events, the paths of the
+// REST API, and [AdminEndpoint] for the admin API if it's enabled)
 // Values: The handler for the API
 func (vm *VM) CreateHandlers(_ context.Context) (map[string]*common.HTTPHandler, error) {
+	service := &Service{vm: vm}
 	server := rpc.NewServer()
 	server.RegisterCodec(json.NewCodec(), "application/json")
 	server.RegisterCodec(json.NewCodec(), "application/json;charset=UTF-8")
-	if err := server.RegisterService(&Service{vm: vm}, Name); err != nil {
+	server.RegisterAfterFunc(vm.metrics.observeAPICall)
+	if err := server.RegisterService(service, Name); err != nil {
 		return nil, err
 	}
 
-	return map[string]*common.HTTPHandler{
-		"": {
+	handlers := newRESTHandler(service).handlers()
+	handlers[""] = &common.HTTPHandler{
+		LockOptions: common.WriteLock,
+		Handler:     server,
+	}
+	// The handler takes the context lock itself, so it doesn't hold it while
+	// waiting for events
+	handlers[EventsEndpoint] = &common.HTTPHandler{
+		LockOptions: common.NoLock,
+		Handler:     newEventsHandler(vm),
+	}
+
+	if vm.config.AdminAPIEnabled {
+		adminServer := rpc.NewServer()
+		adminServer.RegisterCodec(json.NewCodec(), "application/json")
+		adminServer.RegisterCodec(json.NewCodec(), "application/json;charset=UTF-8")
+		adminServer.RegisterAfterFunc(vm.metrics.observeAPICall)
+		if err := adminServer.RegisterService(&AdminService{vm: vm}, AdminName); err != nil {
+			return nil, err
+		}
+		handlers[AdminEndpoint] = &common.HTTPHandler{
 			LockOptions: common.WriteLock,
-			Handler:     server,
-		},
-	}, nil
+			Handler:     adminServer,
+		}
+	}
+	return handlers, nil
 }
 
 // CreateStaticHandlers returns a map where:
@@ -213,42 +613,98 @@ func (*VM) CreateStaticHandlers(_ context.Context) (map[string]*common.HTTPHandl
 	}, nil
 }
 
-// Health implements the common.VM interface
-func (*VM) HealthCheck(_ context.Context) (interface{}, error) { return nil, nil }
-
 // BuildBlock returns a block that this vm wants to add to consensus
 func (vm *VM) BuildBlock(ctx context.Context) (snowman.Block, error) {
-	if len(vm.mempool) == 0 { // There is no block to be built
-		return nil, errNoPendingBlocks
+	if vm.buildingPaused.Get() {
+		return nil, errBuildingPaused
 	}
 
-	// Get the value to put in the new block
-	value := vm.mempool[0]
-	vm.mempool = vm.mempool[1:]
+	// The upgrades active now decide the format of the new block
+	timestamp := time.Now()
+	version := vm.upgrades.BlockVersion(timestamp)
+	maxEntries := MaxBlockEntries
+	if version == BlockVersion0 {
+		maxEntries = 1
+	}
+
+	// Get the values to put in the new block, as many as fit in a block
+	var (
+		entries     = make([][]byte, 0, maxEntries)
+		submissions = make([]Submission, 0, maxEntries)
+		usedNonces  = make(map[nonceKey]struct{})
+		size        = 0
+	)
+	for len(entries) < maxEntries {
+		value, ok := vm.mempool.Peek()
+		if !ok || size+len(value) > MaxBlockDataSize {
+			break
+		}
+		sub := vm.mempool.Submission(value)
+		vm.mempool.Pop()
+		if !fitsBlockVersion(value, version) {
+			vm.snowCtx.Log.Debug("dropping data not fitting the block version",
+				zap.Int("length", len(value)),
+				zap.Uint16("version", version),
+			)
+			vm.dropped.drop(value, dropCauseInvalid, errFixedDataLen.Error())
+			continue
+		}
+		if sub == nil {
+			entries = append(entries, value)
+			submissions = append(submissions, Submission{})
+			size += len(value)
+			continue
+		}
+
+		// Signed data can only be put into a block once its nonce is used
+		// neither by this block nor by the blocks it builds on
+		if err := vm.verifyNonce(sub, usedNonces); err != nil {
+			vm.snowCtx.Log.Debug("dropping signed data",
+				zap.Stringer("signer", sub.Signer),
+				zap.Uint64("nonce", sub.Nonce),
+				zap.Error(err),
+			)
+			vm.dropped.drop(value, dropCauseInvalid, err.Error())
+			continue
+		}
+		usedNonces[nonceKey{signer: sub.Signer, nonce: sub.Nonce}] = struct{}{}
+		entries = append(entries, value)
+		submissions = append(submissions, *sub)
+		size += len(value)
+	}
+	if len(entries) == 0 { // There is no block to be built
+		return nil, errNoPendingBlocks
+	}
 
 	// Notify consensus engine that there are more pending data for blocks
 	// (if that is the case) when done building this block
-	if len(vm.mempool) > 0 {
+	if vm.mempool.Len() > 0 {
 		defer vm.NotifyBlockReady()
 	}
 
 	// Gets Preferred Block
 	preferredBlock, err := vm.getBlock(vm.preferred)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't get preferred block: %w", err)
+		err = fmt.Errorf("couldn't get preferred block: %w", err)
+		vm.dropped.dropAll(entries, dropCauseBuildFailed, err.Error())
+		return nil, err
 	}
 	preferredHeight := preferredBlock.Height()
 
 	// Build the block with preferred height
-	newBlock, err := vm.NewBlock(vm.preferred, preferredHeight+1, value, time.Now())
+	newBlock, err := vm.newBlock(vm.preferred, preferredHeight+1, entries, submissions, timestamp)
 	if err != nil {
-		return nil, fmt.Errorf("couldn't build block: %w", err)
+		err = fmt.Errorf("couldn't build block: %w", err)
+		vm.dropped.dropAll(entries, dropCauseBuildFailed, err.Error())
+		return nil, err
 	}
 
 	// Verifies block
 	if err := newBlock.Verify(ctx); err != nil {
+		vm.dropped.dropAll(entries, dropCauseBuildFailed, fmt.Sprintf("built block %s failed verification: %s", newBlock.ID(), err))
 		return nil, err
 	}
+	vm.metrics.blocksBuilt.Inc()
 	return newBlock, nil
 }
 
@@ -276,20 +732,320 @@ func (vm *VM) getBlock(blkID ids.ID) (*Block, error) {
 	return vm.state.GetBlock(blkID)
 }
 
+// addVerifiedBlock keeps [blk] in memory, and indexes it by the data it
+// holds, until it's accepted or rejected
+func (vm *VM) addVerifiedBlock(blk *Block) {
+	blkID := blk.ID()
+	vm.verifiedBlocks[blkID] = blk
+	for _, data := range blk.Entries() {
+		id := dataID(data)
+		blkIDs, ok := vm.verifiedData[id]
+		if !ok {
+			blkIDs = set.NewSet[ids.ID](1)
+			vm.verifiedData[id] = blkIDs
+		}
+		blkIDs.Add(blkID)
+	}
+}
+
+// removeVerifiedBlock removes [blk], accepted or rejected, from memory
+func (vm *VM) removeVerifiedBlock(blk *Block) {
+	blkID := blk.ID()
+	if _, ok := vm.verifiedBlocks[blkID]; !ok {
+		return
+	}
+	delete(vm.verifiedBlocks, blkID)
+	for _, data := range blk.Entries() {
+		id := dataID(data)
+		blkIDs := vm.verifiedData[id]
+		blkIDs.Remove(blkID)
+		if blkIDs.Len() == 0 {
+			delete(vm.verifiedData, id)
+		}
+	}
+}
+
+// accumulatorAfter returns the accumulator over the data accepted up to and
+// including [blk], as it is or will be once [blk] is accepted
+func (vm *VM) accumulatorAfter(blk *Block) (*Accumulator, error) {
+	if blk.acc != nil {
+		return blk.acc, nil
+	}
+
+	if blk.Status() == choices.Accepted {
+		size, err := vm.state.GetAccumulatorSizeAtHeight(blk.Height())
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get accumulator size at height %d: %w", blk.Height(), err)
+		}
+		return vm.state.GetAccumulator(size)
+	}
+
+	// The data of [blk] is appended to the accumulator after its parent
+	parent, err := vm.getBlock(blk.Parent())
+	if err != nil {
+		return nil, errDatabaseGet
+	}
+	parentAcc, err := vm.accumulatorAfter(parent)
+	if err != nil {
+		return nil, err
+	}
+	blk.acc = parentAcc.with(blk.Entries())
+	return blk.acc, nil
+}
+
+// VerifyHeightIndex implements the block.HeightIndexedChainVM interface
+func (vm *VM) VerifyHeightIndex(_ context.Context) error {
+	heightIndexed, err := vm.state.IsHeightIndexed()
+	if err != nil {
+		return err
+	}
+	if !heightIndexed {
+		return block.ErrIndexIncomplete
+	}
+
+	// Blocks missing after state syncing aren't indexed yet
+	_, err = vm.state.GetMissingBlockID()
+	switch err {
+	case nil:
+		return block.ErrIndexIncomplete
+	case database.ErrNotFound:
+		return nil
+	default:
+		return err
+	}
+}
+
+// GetBlockIDAtHeight implements the block.HeightIndexedChainVM interface
+func (vm *VM) GetBlockIDAtHeight(_ context.Context, height uint64) (ids.ID, error) {
+	return vm.state.GetBlockIDAtHeight(height)
+}
+
+// StateSyncEnabled implements the block.StateSyncableVM interface
+func (vm *VM) StateSyncEnabled(_ context.Context) (bool, error) {
+	return vm.config.StateSyncEnabled, nil
+}
+
+// GetOngoingSyncStateSummary implements the block.StateSyncableVM interface.
+// Syncing to a summary completes when it's accepted, so there is never an
+// ongoing summary.
+func (*VM) GetOngoingSyncStateSummary(_ context.Context) (block.StateSummary, error) {
+	return nil, database.ErrNotFound
+}
+
+// GetLastStateSummary implements the block.StateSyncableVM interface
+func (vm *VM) GetLastStateSummary(ctx context.Context) (block.StateSummary, error) {
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return nil, err
+	}
+	lastAcceptedBlock, err := vm.getBlock(lastAccepted)
+	if err != nil {
+		return nil, err
+	}
+	height := lastAcceptedBlock.Height()
+	return vm.GetStateSummary(ctx, height-height%vm.config.StateSummaryFrequency)
+}
+
+// ParseStateSummary implements the block.StateSyncableVM interface
+func (vm *VM) ParseStateSummary(_ context.Context, summaryBytes []byte) (block.StateSummary, error) {
+	return parseSummary(summaryBytes, vm)
+}
+
+// GetStateSummary implements the block.StateSyncableVM interface.
+// Summaries are made at the heights that are a multiple of the configured
+// state summary frequency, except for the genesis block.
+func (vm *VM) GetStateSummary(_ context.Context, height uint64) (block.StateSummary, error) {
+	if height == 0 || height%vm.config.StateSummaryFrequency != 0 {
+		return nil, database.ErrNotFound
+	}
+	blkID, err := vm.state.GetBlockIDAtHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	blk, err := vm.state.GetBlock(blkID)
+	if err != nil {
+		return nil, err
+	}
+	return newSummary(blk, vm)
+}
+
+// acceptSummary makes the block of [summary] the last accepted block, and
+// starts fetching the blocks before it from peers.
+// The summary is skipped if the last accepted block is less than
+// the configured state sync min blocks behind it.
+// If the blocks before it may hold signed data, the engine waits for their
+// nonces to be indexed before verifying new blocks.
+func (vm *VM) acceptSummary(ctx context.Context, summary *Summary) (block.StateSyncMode, error) {
+	// The nonces of a previous sync may still be missing
+	noncesMissing, err := vm.state.AreNoncesMissing()
+	if err != nil {
+		return 0, err
+	}
+	if noncesMissing {
+		vm.snowCtx.Log.Info("waiting for the nonces of the missing blocks")
+		return block.StateSyncStatic, nil
+	}
+
+	lastAccepted, err := vm.state.GetLastAccepted()
+	if err != nil {
+		return 0, err
+	}
+	lastAcceptedBlock, err := vm.getBlock(lastAccepted)
+	if err != nil {
+		return 0, err
+	}
+	if summary.Height() < lastAcceptedBlock.Height()+vm.config.StateSyncMinBlocks {
+		vm.snowCtx.Log.Info("skipping state sync",
+			zap.Uint64("summaryHeight", summary.Height()),
+			zap.Uint64("lastAcceptedHeight", lastAcceptedBlock.Height()),
+		)
+		return block.StateSyncSkipped, nil
+	}
+
+	vm.snowCtx.Log.Info("accepting state summary",
+		zap.Stringer("blkID", summary.blk.ID()),
+		zap.Uint64("height", summary.Height()),
+	)
+
+	// The blocks before the summary's block are missing until they are
+	// fetched from peers, and so is their data in the accumulator
+	blk := summary.blk
+	if err := vm.state.SetAccumulator(blk.Height()-1, &summary.Acc); err != nil {
+		return 0, err
+	}
+	if err := vm.state.SetMissingBlockID(blk.Parent()); err != nil {
+		return 0, err
+	}
+	// The blocks before the summary's block may use nonces once signed data
+	// is active
+	noncesMissing = vm.upgrades.IsActivated(SignedDataUpgrade, blk.Timestamp())
+	if noncesMissing {
+		if err := vm.state.SetNoncesMissing(); err != nil {
+			return 0, err
+		}
+	}
+	// Sets the last accepted block and commits the missing block ID with it
+	if err := blk.Accept(ctx); err != nil {
+		return 0, err
+	}
+	if err := vm.SetPreference(ctx, blk.ID()); err != nil {
+		return 0, err
+	}
+	vm.syncer.start()
+
+	// Blocks reusing the nonces of the missing blocks can't be told apart
+	// until the syncer indexes them, and then notifies the engine
+	if noncesMissing {
+		return block.StateSyncStatic, nil
+	}
+	// This node can build on the summary's block right away
+	return block.StateSyncDynamic, nil
+}
+
 // LastAccepted returns the block most recently accepted
 func (vm *VM) LastAccepted(_ context.Context) (ids.ID, error) { return vm.state.GetLastAccepted() }
 
-// proposeBlock appends [data] to [p.mempool].
+// proposeBlock adds [data] to [vm.mempool].
 // Then it notifies the consensus engine
 // that a new block is ready to be added to consensus
 // (namely, a block with data [data])
-func (vm *VM) proposeBlock(data [DataLen]byte) bool {
-	if len(vm.mempool) > MaxMempoolSize {
-		return false
+// and gossips [data] to the other nodes of the network
+func (vm *VM) proposeBlock(data []byte) error {
+	return vm.proposeSignedBlock(data, nil)
+}
+
+// proposeSignedBlock proposes [data] like [proposeBlock], along with [sub],
+// the submission of its signer. [sub] is nil for unsigned data.
+// The signature of [sub] must be valid, and its nonce unused.
+func (vm *VM) proposeSignedBlock(data []byte, sub *Submission) error {
+	if err := vm.addProposal(data, sub); err != nil {
+		return err
 	}
-	vm.mempool = append(vm.mempool, data)
 	vm.NotifyBlockReady()
-	return true
+	vm.gossiper.add(context.TODO(), data, sub)
+	return nil
+}
+
+// proposeBatch proposes each piece of [batch] like [proposeBlock], and
+// returns the error of each proposal, nil for the proposals added to the
+// mempool. The engine is notified, and the pending gossip flushed, once for
+// the whole batch.
+func (vm *VM) proposeBatch(batch [][]byte) []error {
+	var (
+		errs  = make([]error, len(batch))
+		added = false
+	)
+	for i, data := range batch {
+		if errs[i] = vm.addProposal(data, nil); errs[i] != nil {
+			continue
+		}
+		vm.gossiper.queue(data, nil)
+		added = true
+	}
+	if added {
+		vm.NotifyBlockReady()
+		vm.gossiper.flush(context.TODO())
+	}
+	return errs
+}
+
+// addProposal adds [data], along with [sub], to the mempool once it's checked
+// they can be put into a block, and that [data] isn't accepted already
+func (vm *VM) addProposal(data []byte, sub *Submission) error {
+	if err := verifyDataLen(data, vm.config.MaxDataLen); err != nil {
+		return err
+	}
+	switch _, err := vm.state.GetBlockIDByDataID(dataID(data)); err {
+	case nil:
+		return errAlreadyAccepted
+	case database.ErrNotFound:
+	default:
+		return err
+	}
+	version := vm.upgrades.BlockVersion(time.Now())
+	if !fitsBlockVersion(data, version) {
+		return errFixedDataLen
+	}
+	if sub != nil {
+		if version < BlockVersion4 {
+			return errUnsignedOnly
+		}
+		if err := sub.Verify(vm.snowCtx.ChainID, data); err != nil {
+			return err
+		}
+		if err := vm.verifyNonce(sub, nil); err != nil {
+			return err
+		}
+	}
+	// The oldest data is evicted if the mempool is full and drops the oldest
+	oldest, full := vm.mempool.Peek()
+	full = full && vm.mempool.Len() >= vm.mempool.MaxSize()
+	if err := vm.mempool.AddSigned(data, sub); err != nil {
+		if err == errMempoolFull {
+			vm.dropped.drop(data, dropCauseMempoolFull, err.Error())
+		}
+		return err
+	}
+	if full && !vm.mempool.Has(oldest) {
+		vm.dropped.drop(oldest, dropCauseEvicted, "evicted from the full mempool")
+	}
+	return nil
+}
+
+// verifyNonce returns nil iff the nonce of [sub] is used neither by the
+// preferred block nor by its ancestors, nor is in [usedNonces]
+func (vm *VM) verifyNonce(sub *Submission, usedNonces map[nonceKey]struct{}) error {
+	if _, ok := usedNonces[nonceKey{signer: sub.Signer, nonce: sub.Nonce}]; ok {
+		return errNonceUsed
+	}
+	used, err := vm.isNonceUsed(vm.preferred, sub.Signer, sub.Nonce)
+	if err != nil {
+		return err
+	}
+	if used {
+		return errNonceUsed
+	}
+	return nil
 }
 
 // ParseBlock parses [bytes] to a snowman.Block
@@ -297,48 +1053,133 @@ func (vm *VM) proposeBlock(data [DataLen]byte) bool {
 // and by the consensus layer when it receives the byte representation of a block
 // from another node
 func (vm *VM) ParseBlock(_ context.Context, bytes []byte) (snowman.Block, error) {
-	// A new empty block
-	block := &Block{}
-
-	// Unmarshal the byte repr. of the block into our empty block
-	_, err := Codec.Unmarshal(bytes, block)
+	// Unmarshal the byte repr. of the block into a new block and initialize it
+	block, err := parseBlock(bytes, choices.Processing, vm)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize the block
-	block.Initialize(bytes, choices.Processing, vm)
-
 	if blk, err := vm.getBlock(block.ID()); err == nil {
 		// If we have seen this block before, return it with the most up-to-date
 		// info
 		return blk, nil
 	}
 
+	// Ensure the block has the format scheduled at its timestamp
+	if err := block.verifyVersion(); err != nil {
+		return nil, err
+	}
+
 	// Return the block
 	return block, nil
 }
 
 // NewBlock returns a new Block where:
 // - the block's parent is [parentID]
-// - the block's data is [data]
+// - the block's data is [entries]
 // - the block's timestamp is [timestamp]
-func (vm *VM) NewBlock(parentID ids.ID, height uint64, data [DataLen]byte, timestamp time.Time) (*Block, error) {
+// - the block's version is the one scheduled at [timestamp]
+// - from [BlockVersion3], the block's root is the one of the accumulator
+// after [entries]
+// - from [BlockVersion4], the data is unsigned
+// - from [BlockVersion5], the block's timestamp has millisecond precision
+func (vm *VM) NewBlock(parentID ids.ID, height uint64, entries [][]byte, timestamp time.Time) (*Block, error) {
+	return vm.newBlock(parentID, height, entries, nil, timestamp)
+}
+
+// newBlock returns a new Block like [NewBlock], where the submission of each
+// piece of data is in [submissions]. [submissions] is either nil, for unsigned
+// data, or as long as [entries].
+func (vm *VM) newBlock(parentID ids.ID, height uint64, entries [][]byte, submissions []Submission, timestamp time.Time) (*Block, error) {
 	block := &Block{
-		PrntID: parentID,
-		Hght:   height,
-		Tmstmp: timestamp.Unix(),
-		Dt:     data,
+		PrntID:  parentID,
+		Hght:    height,
+		Tmstmp:  timestamp.Unix(),
+		version: vm.upgrades.BlockVersion(timestamp),
+	}
+	if block.version >= BlockVersion5 {
+		block.Ms = uint16(timestamp.Nanosecond() / int(time.Millisecond))
 	}
 
+	for _, data := range entries {
+		if !fitsBlockVersion(data, block.version) {
+			return nil, fmt.Errorf("%w: %d bytes in a version %d block", errBadEntries, len(data), block.version)
+		}
+	}
+	switch block.version {
+	case BlockVersion0:
+		if len(entries) != 1 {
+			return nil, fmt.Errorf("%w: %d pieces of data in a version %d block", errBadEntries, len(entries), block.version)
+		}
+		block.Dt = BytesToData(entries[0])
+	case BlockVersion1:
+		block.Dts = make([][DataLen]byte, len(entries))
+		for i, data := range entries {
+			block.Dts[i] = BytesToData(data)
+		}
+	default:
+		block.Pylds = entries
+	}
+
+	// Record who submitted the data
+	for _, sub := range submissions {
+		if block.version < BlockVersion4 && sub.IsSigned() {
+			return nil, fmt.Errorf("%w: signed data in a version %d block", errBadEntries, block.version)
+		}
+	}
+	if block.version >= BlockVersion4 {
+		block.Sbmsns = submissions
+		if block.Sbmsns == nil {
+			block.Sbmsns = make([]Submission, len(entries))
+		}
+	}
+
+	// Commit to the accumulator after the data of the block
+	if block.version >= BlockVersion3 {
+		acc, err := vm.accumulatorAfter(block)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get accumulator: %w", err)
+		}
+		block.Rt = acc.Root()
+		block.Sz = acc.Size
+	}
+	return vm.initBlock(block)
+}
+
+// newGenesisBlock returns the genesis block holding [data].
+// Genesis data of up to [DataLen] bytes is put in a [BlockVersion0] block,
+// padded with zeros, so existing chains keep their genesis block.
+// Longer genesis data is put in a [BlockVersion2] block as it is, if the
+// variable length data upgrade is active from genesis.
+func (vm *VM) newGenesisBlock(data []byte) (*Block, error) {
+	// Timestamp of genesis block is 0. It has no parent.
+	block := &Block{
+		PrntID: ids.Empty,
+		Hght:   0,
+		Tmstmp: 0,
+	}
+	switch {
+	case len(data) <= DataLen:
+		block.Dt = BytesToData(data)
+		block.version = BlockVersion0
+	case !vm.upgrades.IsActivated(VariableLengthDataUpgrade, time.Unix(0, 0)):
+		return nil, errFixedDataLen
+	default:
+		block.Pylds = [][]byte{data}
+		block.version = BlockVersion2
+	}
+	return vm.initBlock(block)
+}
+
+// initBlock encodes [block] with its version and initializes it by providing
+// it with its byte representation and a reference to this VM
+func (vm *VM) initBlock(block *Block) (*Block, error) {
 	// Get the byte representation of the block
-	blockBytes, err := Codec.Marshal(CodecVersion, block)
+	blockBytes, err := Codec.Marshal(block.version, block)
 	if err != nil {
 		return nil, err
 	}
 
-	// Initialize the block by providing it with its byte representation
-	// and a reference to this VM
 	block.Initialize(blockBytes, choices.Processing, vm)
 	return block, nil
 }
@@ -349,6 +1190,10 @@ func (vm *VM) Shutdown(_ context.Context) error {
 		return nil
 	}
 
+	// stop the gossip and sync loops
+	vm.gossiper.shutdown()
+	vm.syncer.shutdown()
+
 	return vm.state.Close() // close versionDB
 }
 
@@ -361,6 +1206,9 @@ func (vm *VM) SetPreference(_ context.Context, id ids.ID) error {
 // SetState sets this VM state according to given snow.State
 func (vm *VM) SetState(_ context.Context, state snow.State) error {
 	switch state {
+	// Engine reports it's state syncing
+	case snow.StateSyncing:
+		return vm.onBootstrapStarted()
 	// Engine reports it's bootstrapping
 	case snow.Bootstrapping:
 		return vm.onBootstrapStarted()
@@ -393,42 +1241,103 @@ func (*VM) Version(_ context.Context) (string, error) {
 	return Version.String(), nil
 }
 
-func (*VM) Connected(_ context.Context, _ ids.NodeID, _ *version.Application) error {
-	return nil // noop
+func (vm *VM) Connected(_ context.Context, nodeID ids.NodeID, _ *version.Application) error {
+	vm.syncer.connected(nodeID)
+	return nil
 }
 
-func (*VM) Disconnected(_ context.Context, _ ids.NodeID) error {
-	return nil // noop
+func (vm *VM) Disconnected(_ context.Context, nodeID ids.NodeID) error {
+	vm.gossiper.disconnected(nodeID)
+	vm.syncer.disconnected(nodeID)
+	return nil
 }
 
-// This VM doesn't (currently) have any app-specific messages
-func (*VM) AppGossip(_ context.Context, _ ids.NodeID, _ []byte) error {
-	return nil
+// AppGossip handles the data gossiped by [nodeID]
+// Invalid messages are dropped, as returning an error is fatal to the chain
+func (vm *VM) AppGossip(_ context.Context, nodeID ids.NodeID, msgBytes []byte) error {
+	msg, err := ParseMessage(msgBytes)
+	if err != nil {
+		vm.snowCtx.Log.Debug("dropping unparsable gossip message",
+			zap.Stringer("nodeID", nodeID),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	// App messages are not synchronized by the consensus engine
+	vm.snowCtx.Lock.Lock()
+	defer vm.snowCtx.Lock.Unlock()
+
+	return msg.Handle(vm.gossiper, nodeID)
 }
 
-// This VM doesn't (currently) have any app-specific messages
-func (*VM) AppRequest(_ context.Context, _ ids.NodeID, _ uint32, _ time.Time, _ []byte) error {
-	return nil
+// AppRequest handles the request [requestID] of [nodeID]
+// Invalid requests are dropped, as returning an error is fatal to the chain
+func (vm *VM) AppRequest(ctx context.Context, nodeID ids.NodeID, requestID uint32, _ time.Time, requestBytes []byte) error {
+	req, err := ParseRequest(requestBytes)
+	if err != nil {
+		vm.snowCtx.Log.Debug("dropping unparsable request",
+			zap.Stringer("nodeID", nodeID),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	// App messages are not synchronized by the consensus engine
+	vm.snowCtx.Lock.Lock()
+	defer vm.snowCtx.Lock.Unlock()
+
+	return req.Handle(ctx, vm.syncer, nodeID, requestID)
 }
 
-// This VM doesn't (currently) have any app-specific messages
-func (*VM) AppResponse(_ context.Context, _ ids.NodeID, _ uint32, _ []byte) error {
-	return nil
+// AppResponse handles the response of [nodeID] to the request [requestID].
+// The only requests this VM sends are for the blocks missing after state
+// syncing.
+func (vm *VM) AppResponse(ctx context.Context, nodeID ids.NodeID, requestID uint32, responseBytes []byte) error {
+	// App messages are not synchronized by the consensus engine
+	vm.snowCtx.Lock.Lock()
+	defer vm.snowCtx.Lock.Unlock()
+
+	return vm.syncer.handleResponse(ctx, nodeID, requestID, responseBytes)
 }
 
-// This VM doesn't (currently) have any app-specific messages
-func (*VM) AppRequestFailed(_ context.Context, _ ids.NodeID, _ uint32) error {
+// AppRequestFailed handles the failure of the request [requestID] to [nodeID]
+func (vm *VM) AppRequestFailed(_ context.Context, nodeID ids.NodeID, requestID uint32) error {
+	// App messages are not synchronized by the consensus engine
+	vm.snowCtx.Lock.Lock()
+	defer vm.snowCtx.Lock.Unlock()
+
+	vm.syncer.requestFailed(nodeID, requestID)
 	return nil
 }
 
-func (*VM) CrossChainAppRequest(_ context.Context, _ ids.ID, _ uint32, _ time.Time, _ []byte) error {
-	return nil
+// CrossChainAppRequest handles the request [requestID] of the chain [chainID]
+// Invalid requests are dropped, as returning an error is fatal to the chain
+func (vm *VM) CrossChainAppRequest(ctx context.Context, chainID ids.ID, requestID uint32, _ time.Time, requestBytes []byte) error {
+	req, err := ParseCrossChainRequest(requestBytes)
+	if err != nil {
+		vm.snowCtx.Log.Debug("dropping unparsable cross chain request",
+			zap.Stringer("chainID", chainID),
+			zap.Error(err),
+		)
+		return nil
+	}
+
+	// App messages are not synchronized by the consensus engine
+	vm.snowCtx.Lock.Lock()
+	defer vm.snowCtx.Lock.Unlock()
+
+	return req.Handle(ctx, vm.crossChain, chainID, requestID)
 }
 
+// CrossChainAppRequestFailed is a no-op, as this VM doesn't send requests to
+// other chains
 func (*VM) CrossChainAppRequestFailed(_ context.Context, _ ids.ID, _ uint32) error {
 	return nil
 }
 
+// CrossChainAppResponse is a no-op, as this VM doesn't send requests to other
+// chains
 func (*VM) CrossChainAppResponse(_ context.Context, _ ids.ID, _ uint32, _ []byte) error {
 	return nil
 }
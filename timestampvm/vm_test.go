@@ -5,17 +5,44 @@ package timestampvm
 
 import (
 	"context"
+	stdjson "encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/ava-labs/avalanchego/database"
 	"github.com/ava-labs/avalanchego/database/manager"
+	"github.com/ava-labs/avalanchego/database/memdb"
 	"github.com/ava-labs/avalanchego/ids"
 	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/snow/choices"
 	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/snow/engine/snowman/block"
+	"github.com/ava-labs/avalanchego/snow/validators"
+	"github.com/ava-labs/avalanchego/utils/crypto/bls"
+	"github.com/ava-labs/avalanchego/utils/crypto/secp256k1"
+	"github.com/ava-labs/avalanchego/utils/formatting"
+	"github.com/ava-labs/avalanchego/utils/hashing"
+	"github.com/ava-labs/avalanchego/utils/json"
+	"github.com/ava-labs/avalanchego/utils/logging"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/avalanchego/utils/units"
 	"github.com/ava-labs/avalanchego/version"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/require"
 )
 
-var blockchainID = ids.ID{1, 2, 3}
+var (
+	blockchainID = ids.ID{1, 2, 3}
+
+	// genesisUpgrades activates every upgrade from genesis
+	genesisUpgrades = []byte(`[{"name":"multiEntry","timestamp":0},{"name":"variableLengthData","timestamp":0},{"name":"merkleRoot","timestamp":0},{"name":"signedData","timestamp":0},{"name":"millisecondTimestamp","timestamp":0}]`)
+)
 
 // require that after initialization, the vm has the state we expect
 func TestGenesis(t *testing.T) {
@@ -41,7 +68,8 @@ func TestGenesis(t *testing.T) {
 
 	// Verify that the genesis block has the data we expect
 	require.Equal(ids.Empty, genesisBlock.Parent())
-	require.Equal([32]byte{0, 0, 0, 0, 0}, genesisBlock.Data())
+	genesisData := [DataLen]byte{0, 0, 0, 0, 0}
+	require.Equal([][]byte{genesisData[:]}, genesisBlock.Entries())
 }
 
 func TestHappyPath(t *testing.T) {
@@ -61,7 +89,7 @@ func TestHappyPath(t *testing.T) {
 	require.NoError(vm.SetPreference(ctx, genesisBlock.ID()))
 
 	snowCtx.Lock.Lock()
-	vm.proposeBlock([DataLen]byte{0, 0, 0, 0, 1}) // propose a value
+	require.NoError(vm.proposeBlock([]byte{0, 0, 0, 0, 1})) // propose a value
 	snowCtx.Lock.Unlock()
 
 	select { // require there is a pending tx message to the engine
@@ -89,11 +117,11 @@ func TestHappyPath(t *testing.T) {
 
 	// require the block we accepted has the data we expect
 	require.Equal(genesisBlock.ID(), block2.Parent())
-	require.Equal([DataLen]byte{0, 0, 0, 0, 1}, block2.Data())
+	require.Equal([][]byte{{0, 0, 0, 0, 1}}, block2.Entries())
 	require.Equal(snowmanBlock2.ID(), block2.ID())
 	require.NoError(block2.Verify(ctx))
 
-	vm.proposeBlock([DataLen]byte{0, 0, 0, 0, 2}) // propose a block
+	require.NoError(vm.proposeBlock([]byte{0, 0, 0, 0, 2})) // propose a block
 	snowCtx.Lock.Unlock()
 
 	select { // verify there is a pending tx message to the engine
@@ -120,7 +148,7 @@ func TestHappyPath(t *testing.T) {
 
 	// require the block we accepted has the data we expect
 	require.Equal(snowmanBlock2.ID(), block3.Parent())
-	require.Equal([DataLen]byte{0, 0, 0, 0, 2}, block3.Data())
+	require.Equal([][]byte{{0, 0, 0, 0, 2}}, block3.Entries())
 	require.Equal(snowmanBlock3.ID(), block3.ID())
 	require.NoError(block3.Verify(ctx))
 
@@ -136,6 +164,271 @@ func TestHappyPath(t *testing.T) {
 	snowCtx.Lock.Unlock()
 }
 
+// require that data included in a block built by another node is dropped
+// from the mempool once that block is accepted
+func TestAcceptRemovesFromMempool(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+
+	require.NoError(vm.proposeBlock([]byte{1}))
+	require.NoError(vm.proposeBlock([]byte{2}))
+	require.ErrorIs(vm.proposeBlock([]byte{1}), errDuplicateData)
+
+	lastAcceptedID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(lastAcceptedID, 1, [][]byte{{1}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	require.Equal([][]byte{{2}}, vm.mempool.Contents())
+
+	// accepted data isn't proposed again
+	require.ErrorIs(vm.proposeBlock([]byte{1}), errAlreadyAccepted)
+	require.Equal([][]byte{{2}}, vm.mempool.Contents())
+}
+
+// require that a built block holds as many pieces of data from the mempool
+// as fit in a block
+func TestBuildBlockDrainsMempool(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+
+	for i := 0; i < MaxBlockEntries+1; i++ {
+		require.NoError(vm.proposeBlock([]byte{byte(i), byte(i >> 8)}))
+	}
+
+	snowmanBlock, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	blk := snowmanBlock.(*Block)
+	require.Equal(uint16(BlockVersion5), blk.Version())
+	require.Len(blk.Entries(), MaxBlockEntries)
+	require.Equal(1, vm.mempool.Len())
+
+	// the parsed block holds the same data
+	parsedBlock, err := parseBlock(blk.Bytes(), choices.Processing, vm)
+	require.NoError(err)
+	require.Equal(blk.ID(), parsedBlock.ID())
+	require.Equal(blk.Entries(), parsedBlock.Entries())
+
+	// blocks without data are invalid
+	emptyBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), nil, time.Now())
+	require.NoError(err)
+	require.ErrorIs(emptyBlock.Verify(ctx), errNoEntries)
+}
+
+func TestVariableLengthData(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+
+	// data longer than the configured maximum is rejected rather than truncated
+	require.ErrorIs(vm.proposeBlock(make([]byte, DefaultMaxDataLen+1)), errDataTooLarge)
+	require.ErrorIs(vm.proposeBlock(nil), errEmptyData)
+
+	digest := hashing.ComputeHash256(nil)
+	digest = append(digest, digest...) // 64 bytes, like a SHA-512 digest
+	require.NoError(vm.proposeBlock(digest))
+	require.NoError(vm.proposeBlock([]byte(`{"doc":"manifest"}`)))
+
+	snowmanBlock, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	require.Equal([][]byte{digest, []byte(`{"doc":"manifest"}`)}, snowmanBlock.(*Block).Entries())
+
+	// blocks can't hold more than [MaxBlockDataSize] bytes of data
+	tooLargeBlock, err := vm.NewBlock(
+		snowmanBlock.Parent(),
+		snowmanBlock.Height(),
+		[][]byte{make([]byte, MaxDataLen), make([]byte, MaxDataLen), {1}},
+		time.Now(),
+	)
+	require.NoError(err)
+	require.ErrorIs(tooLargeBlock.Verify(ctx), errBlockTooLarge)
+}
+
+// require that genesis data longer than [DataLen] is kept as it is
+func TestLongGenesis(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	genesisData := make([]byte, 2*DataLen)
+	genesisData[2*DataLen-1] = 1
+	vm := &VM{}
+	snowCtx := snow.DefaultContextTest()
+	dbManager := manager.NewMemDB(&version.Semantic{Major: 1})
+	require.NoError(vm.Initialize(ctx, snowCtx, dbManager, genesisData, genesisUpgrades, nil, nil, nil, &common.SenderTest{}))
+
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	genesisBlock, err := vm.getBlock(lastAccepted)
+	require.NoError(err)
+	require.Equal([][]byte{genesisData}, genesisBlock.Entries())
+	require.NoError(vm.Shutdown(ctx))
+
+	// long genesis data needs the variable length data upgrade from genesis
+	vm = &VM{}
+	dbManager = manager.NewMemDB(&version.Semantic{Major: 1})
+	require.ErrorIs(vm.Initialize(ctx, snow.DefaultContextTest(), dbManager, genesisData, nil, nil, nil, nil, &common.SenderTest{}), errFixedDataLen)
+	vm = &VM{}
+	dbManager = manager.NewMemDB(&version.Semantic{Major: 1})
+	lateUpgrades := []byte(`[{"name":"multiEntry","timestamp":0},{"name":"variableLengthData","timestamp":1}]`)
+	require.ErrorIs(vm.Initialize(ctx, snow.DefaultContextTest(), dbManager, genesisData, lateUpgrades, nil, nil, nil, &common.SenderTest{}), errFixedDataLen)
+}
+
+func TestConfig(t *testing.T) {
+	require := require.New(t)
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":1,"maxDataLen":8}`), &common.SenderTest{})
+	require.NoError(err)
+	require.Equal(1, vm.config.MempoolSize)
+
+	require.ErrorIs(vm.proposeBlock(make([]byte, 9)), errDataTooLarge)
+	require.NoError(vm.proposeBlock([]byte{1}))
+	require.ErrorIs(vm.proposeBlock([]byte{2}), errMempoolFull)
+
+	// invalid configs fail initialization
+	_, _, _, err = newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":-1}`), &common.SenderTest{})
+	require.ErrorIs(err, errInvalidMempoolSize)
+}
+
+// require that the logLevel config sets the level of the VM's logs
+func TestLogLevel(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	defer func() { require.NoError(setLogLevel(logging.NoLog{}, DefaultLogLevel)) }()
+
+	vm := &VM{}
+	snowCtx := snow.DefaultContextTest()
+	snowCtx.Log = logging.NewLogger("", logging.NewWrappedCore(logging.Info, logging.Discard, logging.Plain.ConsoleEncoder()))
+	dbManager := manager.NewMemDB(&version.Semantic{Major: 1})
+	require.NoError(vm.Initialize(ctx, snowCtx, dbManager, []byte{0, 0, 0, 0, 0}, genesisUpgrades, []byte(`{"logLevel":"debug"}`), make(chan common.Message, 1), nil, &common.SenderTest{}))
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	require.True(snowCtx.Log.Enabled(logging.Debug))
+	require.False(snowCtx.Log.Enabled(logging.Verbo))
+
+	_, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"logLevel":"loud"}`), &common.SenderTest{})
+	require.ErrorIs(err, logging.ErrUnknownLevel)
+}
+
+// require that the block format follows the upgrade schedule
+func TestUpgrades(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	now := time.Now()
+	upgradeBytes := []byte(fmt.Sprintf(
+		`[{"name":%q,"timestamp":%d},{"name":%q,"timestamp":%d},{"name":%q,"timestamp":%d},{"name":%q,"timestamp":%d},{"name":%q,"timestamp":%d}]`,
+		MultiEntryUpgrade, now.Add(10*time.Minute).Unix(),
+		VariableLengthDataUpgrade, now.Add(2*time.Hour).Unix(),
+		MerkleRootUpgrade, now.Add(3*time.Hour).Unix(),
+		SignedDataUpgrade, now.Add(4*time.Hour).Unix(),
+		MillisecondTimestampUpgrade, now.Add(5*time.Hour).Unix(),
+	))
+	vm, _, _, err := newTestVMWithConfig(upgradeBytes, nil, &common.SenderTest{})
+	require.NoError(err)
+
+	// before the variable length data upgrade only [DataLen] bytes are accepted
+	require.ErrorIs(vm.proposeBlock([]byte{1}), errFixedDataLen)
+	require.NoError(vm.proposeBlock(append(make([]byte, DataLen-1), 3)))
+	require.NoError(vm.proposeBlock(append(make([]byte, DataLen-1), 1)))
+
+	// before the signed data upgrade data can't be signed
+	key, err := (&secp256k1.Factory{}).NewPrivateKey()
+	require.NoError(err)
+	data := append(make([]byte, DataLen-1), 2)
+	sub, err := SignSubmission(key, vm.snowCtx.ChainID, 0, data)
+	require.NoError(err)
+	require.ErrorIs(vm.proposeSignedBlock(data, sub), errUnsignedOnly)
+
+	// before the multi entry upgrade blocks hold a single piece of data
+	snowmanBlock, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	blk := snowmanBlock.(*Block)
+	require.Equal(uint16(BlockVersion0), blk.Version())
+	require.Equal([][]byte{append(make([]byte, DataLen-1), 3)}, blk.Entries())
+	require.Equal(1, vm.mempool.Len())
+
+	// a block with the wrong format for its timestamp is invalid
+	badBlock := &Block{
+		PrntID:  blk.Parent(),
+		Hght:    blk.Height(),
+		Tmstmp:  blk.Tmstmp,
+		Dts:     [][DataLen]byte{{1}},
+		version: BlockVersion1,
+	}
+	_, err = vm.initBlock(badBlock)
+	require.NoError(err)
+	require.ErrorIs(badBlock.Verify(ctx), errWrongBlockVersion)
+	_, err = vm.ParseBlock(ctx, badBlock.Bytes())
+	require.ErrorIs(err, errWrongBlockVersion)
+
+	// once activated, blocks take the format of the latest upgrade
+	multiEntryBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), [][]byte{make([]byte, DataLen)}, now.Add(10*time.Minute))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion1), multiEntryBlock.Version())
+	require.NoError(multiEntryBlock.Verify(ctx))
+	variableLengthBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), [][]byte{{1}}, now.Add(2*time.Hour))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion2), variableLengthBlock.Version())
+	merkleRootBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), [][]byte{{1}}, now.Add(3*time.Hour))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion3), merkleRootBlock.Version())
+	signedDataBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), [][]byte{{1}}, now.Add(4*time.Hour))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion4), signedDataBlock.Version())
+	require.Equal([]Submission{{}}, signedDataBlock.Submissions())
+	millisecondBlock, err := vm.NewBlock(blk.Parent(), blk.Height(), [][]byte{{1}}, now.Add(5*time.Hour+time.Millisecond))
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion5), millisecondBlock.Version())
+	require.Equal(now.Add(5*time.Hour+time.Millisecond).UnixMilli(), millisecondBlock.Timestamp().UnixMilli())
+	require.Equal(now.Add(4*time.Hour).Unix(), signedDataBlock.Timestamp().Unix())
+	require.Zero(signedDataBlock.Timestamp().Nanosecond())
+
+	// signed data can't be put into blocks before the upgrade
+	_, err = vm.newBlock(blk.Parent(), blk.Height(), [][]byte{data}, []Submission{*sub}, now.Add(3*time.Hour))
+	require.ErrorIs(err, errBadEntries)
+}
+
+// require that a node started without upgrades keeps building and verifying
+// the blocks of the chains that predate them
+func TestUnscheduledUpgrades(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(nil, nil, &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+
+	// a block built by a node that predates the upgrades
+	legacyBlock := &Block{
+		PrntID:  genesisID,
+		Hght:    1,
+		Tmstmp:  time.Now().Unix(),
+		Dt:      [DataLen]byte{1},
+		version: BlockVersion0,
+	}
+	_, err = vm.initBlock(legacyBlock)
+	require.NoError(err)
+	parsed, err := vm.ParseBlock(ctx, legacyBlock.Bytes())
+	require.NoError(err)
+	require.NoError(parsed.Verify(ctx))
+
+	require.NoError(vm.proposeBlock(append(make([]byte, DataLen-1), 2)))
+	built, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	require.Equal(uint16(BlockVersion0), built.(*Block).Version())
+}
+
 func TestService(t *testing.T) {
 	// Initialize the vm
 	require := require.New(t)
@@ -146,25 +439,1446 @@ func TestService(t *testing.T) {
 	require.NoError(service.GetBlock(nil, &GetBlockArgs{}, &GetBlockReply{}))
 }
 
-func TestSetState(t *testing.T) {
-	// Initialize the vm
+// require that accepted blocks can be looked up by height
+func TestHeightIndex(t *testing.T) {
 	require := require.New(t)
 	ctx := context.TODO()
-	// Initialize the vm
+
 	vm, _, _, err := newTestVM()
 	require.NoError(err)
-	// bootstrapping
-	require.NoError(vm.SetState(ctx, snow.Bootstrapping))
-	require.False(vm.bootstrapped.Get())
-	// bootstrapped
+	require.NoError(vm.VerifyHeightIndex(ctx))
+
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+
+	// only accepted blocks are indexed
+	_, err = vm.GetBlockIDAtHeight(ctx, 1)
+	require.ErrorIs(err, database.ErrNotFound)
+	require.NoError(blk.Accept(ctx))
+
+	blkID, err := vm.GetBlockIDAtHeight(ctx, 0)
+	require.NoError(err)
+	require.Equal(genesisID, blkID)
+	blkID, err = vm.GetBlockIDAtHeight(ctx, 1)
+	require.NoError(err)
+	require.Equal(blk.ID(), blkID)
+
+	service := Service{vm}
+	reply := &GetBlockReply{}
+	require.NoError(service.GetBlockByHeight(nil, &GetBlockByHeightArgs{Height: 1}, reply))
+	require.Equal(blk.ID(), reply.ID)
+	require.Equal(json.Uint64(1), reply.Height)
+	require.ErrorIs(service.GetBlockByHeight(nil, &GetBlockByHeightArgs{Height: 2}, reply), errNoSuchBlock)
+}
+
+// require that a node syncs to the state summary of a peer, and then fetches
+// the blocks before it from that peer
+func TestStateSync(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+	configBytes := []byte(`{"stateSyncEnabled":true,"stateSummaryFrequency":4,"stateSyncMinBlocks":4}`)
+
+	// Initialize a vm with a chain of 9 blocks after genesis
+	serverSender := &common.SenderTest{}
+	serverVM, _, _, err := newTestVMWithConfig(genesisUpgrades, configBytes, serverSender)
+	require.NoError(err)
+	defer func() { require.NoError(serverVM.Shutdown(ctx)) }()
+	for i := 0; i < 9; i++ {
+		lastAccepted, err := serverVM.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := serverVM.NewBlock(lastAccepted, uint64(i+1), [][]byte{{byte(i)}}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+	}
+
+	// summaries are made every 4 blocks
+	_, err = serverVM.GetStateSummary(ctx, 5)
+	require.ErrorIs(err, database.ErrNotFound)
+	summary, err := serverVM.GetLastStateSummary(ctx)
+	require.NoError(err)
+	require.Equal(uint64(8), summary.Height())
+	summaryBlkID, err := serverVM.GetBlockIDAtHeight(ctx, 8)
+	require.NoError(err)
+
+	// Initialize a vm syncing from the first one
+	clientSender := &common.SenderTest{}
+	clientVM, clientCtx, clientMsgChan, err := newTestVMWithConfig(genesisUpgrades, configBytes, clientSender)
+	require.NoError(err)
+	defer func() { require.NoError(clientVM.Shutdown(ctx)) }()
+	serverNodeID, clientNodeID := ids.GenerateTestNodeID(), ids.GenerateTestNodeID()
+	clientSender.SendAppRequestF = func(ctx context.Context, _ set.Set[ids.NodeID], requestID uint32, requestBytes []byte) error {
+		go func() {
+			if err := serverVM.AppRequest(ctx, clientNodeID, requestID, time.Time{}, requestBytes); err != nil {
+				t.Error(err)
+			}
+		}()
+		return nil
+	}
+	serverSender.SendAppResponseF = func(ctx context.Context, _ ids.NodeID, requestID uint32, responseBytes []byte) error {
+		go func() {
+			if err := clientVM.AppResponse(ctx, serverNodeID, requestID, responseBytes); err != nil {
+				t.Error(err)
+			}
+		}()
+		return nil
+	}
+	enabled, err := clientVM.StateSyncEnabled(ctx)
+	require.NoError(err)
+	require.True(enabled)
+
+	// accepting the summary makes its block the last accepted block.
+	// Blocks are only verified once the nonces of the blocks before it are
+	// indexed.
+	clientCtx.Lock.Lock()
+	require.NoError(clientVM.Connected(ctx, serverNodeID, nil))
+	parsedSummary, err := clientVM.ParseStateSummary(ctx, summary.Bytes())
+	require.NoError(err)
+	require.Equal(summary.ID(), parsedSummary.ID())
+	mode, err := parsedSummary.Accept(ctx)
+	require.NoError(err)
+	require.Equal(block.StateSyncStatic, mode)
+	lastAccepted, err := clientVM.LastAccepted(ctx)
+	require.NoError(err)
+	require.Equal(summaryBlkID, lastAccepted)
+	require.ErrorIs(clientVM.VerifyHeightIndex(ctx), block.ErrIndexIncomplete)
+
+	// signed data can't be checked until the nonces are indexed
+	key, err := (&secp256k1.Factory{}).NewPrivateKey()
+	require.NoError(err)
+	sub, err := SignSubmission(key, clientVM.snowCtx.ChainID, 0, []byte{9})
+	require.NoError(err)
+	signedBlk, err := clientVM.newBlock(lastAccepted, 9, [][]byte{{9}}, []Submission{*sub}, time.Now())
+	require.NoError(err)
+	require.ErrorIs(signedBlk.Verify(ctx), errNoncesMissing)
+	require.ErrorIs(clientVM.proposeSignedBlock([]byte{9}, sub), errNoncesMissing)
+
+	// the accumulator is synced along with the summary's block
+	serverBlkID, err := serverVM.GetBlockIDAtHeight(ctx, 9)
+	require.NoError(err)
+	serverBlk, err := serverVM.getBlock(serverBlkID)
+	require.NoError(err)
+	clientBlk, err := clientVM.NewBlock(lastAccepted, 9, serverBlk.Entries(), time.Now())
+	require.NoError(err)
+	require.Equal(serverBlk.Rt, clientBlk.Rt)
+	require.NoError(clientBlk.Verify(ctx))
+	clientCtx.Lock.Unlock()
+
+	// the blocks before the summary are fetched from the peer, and the engine
+	// is notified once their nonces are indexed
+	require.Eventually(func() bool {
+		clientCtx.Lock.Lock()
+		defer clientCtx.Lock.Unlock()
+		return clientVM.VerifyHeightIndex(ctx) == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(common.StateSyncDone, <-clientMsgChan)
+	clientCtx.Lock.Lock()
+	require.NoError(signedBlk.Verify(ctx))
+
+	// summaries not far enough ahead of the last accepted block are skipped
+	mode, err = parsedSummary.Accept(ctx)
+	require.NoError(err)
+	require.Equal(block.StateSyncSkipped, mode)
+	clientCtx.Lock.Unlock()
+	for height := uint64(0); height <= 8; height++ {
+		expectedID, err := serverVM.GetBlockIDAtHeight(ctx, height)
+		require.NoError(err)
+		blkID, err := clientVM.GetBlockIDAtHeight(ctx, height)
+		require.NoError(err)
+		require.Equal(expectedID, blkID)
+		blk, err := clientVM.GetBlock(ctx, blkID)
+		require.NoError(err)
+		require.Equal(choices.Accepted, blk.Status())
+	}
+}
+
+// require that a batch of blocks larger than [Codec] allows is served
+func TestLargeBlocksResponse(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	var responseBytes []byte
+	sender := &common.SenderTest{
+		SendAppResponseF: func(_ context.Context, _ ids.NodeID, _ uint32, bytes []byte) error {
+			responseBytes = bytes
+			return nil
+		},
+	}
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, nil, sender)
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// 6 blocks of [MaxDataLen] bytes of data after genesis
+	for i := 0; i < 6; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		data := make([]byte, MaxDataLen)
+		data[0] = byte(i)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), [][]byte{data}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+	}
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+
+	requestBytes, err := BuildRequest(&BlocksRequest{
+		BlkID:     lastAccepted,
+		MaxBlocks: maxSyncBatchSize,
+	})
+	require.NoError(err)
+	require.NoError(vm.AppRequest(ctx, ids.GenerateTestNodeID(), 1, time.Time{}, requestBytes))
+	require.Greater(len(responseBytes), 256*units.KiB)
+
+	vm.syncer.requestedBlkID = lastAccepted
+	blks, err := vm.syncer.parseResponse(responseBytes)
+	require.NoError(err)
+	require.Len(blks, 7)
+	require.Zero(blks[6].Height())
+}
+
+func TestHealthCheck(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":4,"maxMempoolFillRatio":0.5}`), &common.SenderTest{})
+	require.NoError(err)
+
+	// a bootstrapping vm is unhealthy
+	_, err = vm.HealthCheck(ctx)
+	require.ErrorIs(err, errUnhealthy)
 	require.NoError(vm.SetState(ctx, snow.NormalOp))
-	require.True(vm.bootstrapped.Get())
-	// unknown
-	unknownState := snow.State(99)
-	require.ErrorIs(vm.SetState(ctx, unknownState), snow.ErrUnknownState)
+	details, err := vm.HealthCheck(ctx)
+	require.NoError(err)
+	health := details.(*Health)
+	require.True(health.Bootstrapped)
+	require.Zero(health.LastAcceptedHeight)
+
+	// pending data isn't put into a block for longer than the max last
+	// accepted age, as the genesis block is old
+	require.NoError(vm.proposeBlock([]byte{1}))
+	details, err = vm.HealthCheck(ctx)
+	require.ErrorIs(err, errUnhealthy)
+	require.Contains(err.Error(), "last accepted block")
+	require.NotContains(err.Error(), "mempool is")
+
+	require.Equal(1, details.(*Health).MempoolSize)
+
+	// a recently accepted block makes the vm healthy again, until the
+	// mempool is too full
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(lastAccepted, 1, [][]byte{{2}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+	_, err = vm.HealthCheck(ctx)
+	require.NoError(err)
+	require.NoError(vm.proposeBlock([]byte{3}))
+	require.NoError(vm.proposeBlock([]byte{4}))
+	_, err = vm.HealthCheck(ctx)
+	require.ErrorIs(err, errUnhealthy)
+	require.Contains(err.Error(), "mempool is 0.75 full")
+
+	// a closed database is unreachable
+	require.NoError(vm.Shutdown(ctx))
+	details, err = vm.HealthCheck(ctx)
+	require.ErrorIs(err, errUnhealthy)
+	require.NotEqual("healthy", details.(*Health).Database)
 }
 
-func newTestVM() (*VM, *snow.Context, chan common.Message, error) {
+// require that rejected blocks are deleted once they are more than the
+// rejected block retention behind the last accepted block
+func TestRejectedBlockPruning(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"rejectedBlockRetention":1}`), &common.SenderTest{})
+	require.NoError(err)
+	rejectedIndexed, err := vm.state.IsRejectedIndexed()
+	require.NoError(err)
+	require.True(rejectedIndexed)
+
+	// accept a chain of blocks, rejecting a sibling of the first one
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	now := time.Now()
+	rejectedBlk, err := vm.NewBlock(lastAccepted, 1, [][]byte{{0}}, now)
+	require.NoError(err)
+	require.NoError(rejectedBlk.Verify(ctx))
+	var blks []*Block
+	for i := 1; i <= 3; i++ {
+		blk, err := vm.NewBlock(lastAccepted, uint64(i), [][]byte{{byte(i)}}, now)
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		blks = append(blks, blk)
+		lastAccepted = blk.ID()
+	}
+	require.NoError(blks[0].Accept(ctx))
+	require.NoError(rejectedBlk.Reject(ctx))
+
+	// the rejected block is kept while it's at most 1 height behind
+	require.NoError(blks[1].Accept(ctx))
+	blk, err := vm.state.GetBlock(rejectedBlk.ID())
+	require.NoError(err)
+	require.Equal(choices.Rejected, blk.Status())
+
+	require.NoError(blks[2].Accept(ctx))
+	_, err = vm.state.GetBlock(rejectedBlk.ID())
+	require.ErrorIs(err, database.ErrNotFound)
+	_, err = vm.state.GetBlock(blks[0].ID())
+	require.NoError(err)
+
+	// rejected blocks stored before they were indexed are found by the sweep
+	rejectedBlk.SetStatus(choices.Rejected)
+	require.NoError(vm.state.PutBlock(rejectedBlk))
+	require.NoError(vm.state.IndexStoredRejectedBlocks())
+	require.NoError(vm.state.PruneRejectedBlocks(1))
+	_, err = vm.state.GetBlock(rejectedBlk.ID())
+	require.ErrorIs(err, database.ErrNotFound)
+}
+
+// require that blocks commit to the accumulator over the accepted data, and
+// that the inclusion of accepted data can be proven against their root
+func TestAccumulator(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// the genesis data is the first piece of accepted data
+	acc := &Accumulator{}
+	acc.Append(make([]byte, DataLen))
+	for i := 0; i < 5; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		entries := [][]byte{{byte(i)}, {byte(i), 1}, {byte(i), 2}}
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), entries, time.Now())
+		require.NoError(err)
+		for _, data := range entries {
+			acc.Append(data)
+		}
+		require.Equal(acc.Root(), blk.Rt)
+		require.Equal(acc.Size, blk.Sz)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+	}
+
+	// blocks with the wrong root are invalid
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	badBlock, err := vm.NewBlock(lastAccepted, 6, [][]byte{{6}}, time.Now())
+	require.NoError(err)
+	badBlock.Rt = ids.GenerateTestID()
+	_, err = vm.initBlock(badBlock)
+	require.NoError(err)
+	require.ErrorIs(badBlock.Verify(ctx), errWrongRoot)
+
+	encode := func(data []byte) string {
+		str, err := formatting.Encode(formatting.Hex, data)
+		require.NoError(err)
+		return str
+	}
+
+	// data is proven against the root of the last accepted block by default
+	service := &Service{vm: vm}
+	reply := &GetInclusionProofReply{}
+	require.NoError(service.GetInclusionProof(nil, &GetInclusionProofArgs{Height: 2, Data: encode([]byte{1, 1})}, reply))
+	require.Equal(lastAccepted, reply.BlockID)
+	require.Equal(acc.Root(), reply.Root)
+	require.NoError(reply.Proof.Verify([]byte{1, 1}, reply.Root))
+	require.ErrorIs(reply.Proof.Verify([]byte{1, 2}, reply.Root), errBadProof)
+
+	// or against the root of an earlier block
+	rootHeight := json.Uint64(3)
+	require.NoError(service.GetInclusionProof(nil, &GetInclusionProofArgs{Height: 0, Data: encode(make([]byte, DataLen)), RootHeight: &rootHeight}, reply))
+	require.Equal(json.Uint64(3), reply.Height)
+	require.NoError(reply.Proof.Verify(make([]byte, DataLen), reply.Root))
+
+	// data can't be proven against a root before it was accepted
+	rootHeight = 1
+	require.ErrorIs(service.GetInclusionProof(nil, &GetInclusionProofArgs{Height: 2, Data: encode([]byte{1, 1}), RootHeight: &rootHeight}, reply), errRootBeforeData)
+	require.ErrorIs(service.GetInclusionProof(nil, &GetInclusionProofArgs{Height: 2, Data: encode([]byte{5, 1})}, reply), errDataNotInBlock)
+
+	// receipts hold the blocks from the one holding the data up to the
+	// checkpoint
+	receiptReply := &GetReceiptReply{}
+	require.NoError(service.GetReceipt(nil, &GetReceiptArgs{Height: 2, Data: encode([]byte{1, 1})}, receiptReply))
+	require.Equal(lastAccepted, receiptReply.CheckpointID)
+	receiptBytes, err := formatting.Decode(formatting.Hex, receiptReply.Receipt)
+	require.NoError(err)
+	receipt := &Receipt{}
+	_, err = Codec.Unmarshal(receiptBytes, receipt)
+	require.NoError(err)
+	require.Equal([]byte{1, 1}, receipt.Data)
+	require.Len(receipt.Blks, 4)
+	require.ErrorIs(service.GetReceipt(nil, &GetReceiptArgs{Height: 2, Data: encode([]byte{1, 1}), CheckpointHeight: &rootHeight}, receiptReply), errCheckpointBeforeBlock)
+
+	// the accumulator is rebuilt from the accepted blocks if it's missing
+	require.NoError(vm.state.ResetAccumulator())
+	require.NoError(vm.initAccumulator())
+	dataAccumulated, err := vm.state.IsDataAccumulated()
+	require.NoError(err)
+	require.True(dataAccumulated)
+}
+
+// require that receipts are bounded by their size, so the node reads a
+// bounded number of blocks to make them
+func TestReceiptSize(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	numBlks := MaxReceiptSize/MaxDataLen + 1
+	for i := 0; i < numBlks; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		data := make([]byte, MaxDataLen)
+		data[0] = byte(i)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), [][]byte{data}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+	}
+
+	service := &Service{vm: vm}
+	encoded, err := formatting.Encode(formatting.Hex, make([]byte, MaxDataLen))
+	require.NoError(err)
+	reply := &GetReceiptReply{}
+	require.ErrorIs(service.GetReceipt(nil, &GetReceiptArgs{Height: 1, Data: encoded}, reply), errReceiptTooLarge)
+
+	// a closer checkpoint makes a smaller receipt
+	checkpointHeight := json.Uint64(2)
+	require.NoError(service.GetReceipt(nil, &GetReceiptArgs{Height: 1, Data: encoded, CheckpointHeight: &checkpointHeight}, reply))
+	require.Equal(checkpointHeight, reply.CheckpointHeight)
+
+	// the receipt with as many blocks as fit in [MaxReceiptSize] is served,
+	// even though it's larger than [Codec] allows
+	blk, err := service.getAcceptedBlock(1)
+	require.NoError(err)
+	maxBlks := (MaxReceiptSize - MaxDataLen) / len(blk.Bytes())
+	checkpointHeight = json.Uint64(maxBlks)
+	require.NoError(service.GetReceipt(nil, &GetReceiptArgs{Height: 1, Data: encoded, CheckpointHeight: &checkpointHeight}, reply))
+	receiptBytes, err := formatting.Decode(formatting.Hex, reply.Receipt)
+	require.NoError(err)
+	require.Greater(len(receiptBytes), 256*units.KiB)
+	receipt := &Receipt{}
+	_, err = ReceiptCodec.Unmarshal(receiptBytes, receipt)
+	require.NoError(err)
+	require.Len(receipt.Blks, maxBlks)
+
+	// one more block is too many
+	checkpointHeight++
+	require.ErrorIs(service.GetReceipt(nil, &GetReceiptArgs{Height: 1, Data: encoded, CheckpointHeight: &checkpointHeight}, reply), errReceiptTooLarge)
+}
+
+// require that accepted blocks can be looked up by the data they hold
+func TestDataIndex(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// the same data is accepted twice
+	var blks []*Block
+	for i := 0; i < 3; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), [][]byte{{byte(i)}, {9}}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+		blks = append(blks, blk)
+	}
+
+	// the earliest block holding the data is found
+	service := &Service{vm: vm}
+	data, err := formatting.Encode(formatting.Hex, []byte{9})
+	require.NoError(err)
+	reply := &GetBlockReply{}
+	require.NoError(service.GetBlockByData(nil, &GetBlockByDataArgs{Data: data}, reply))
+	require.Equal(blks[0].ID(), reply.ID)
+	require.Equal(json.Uint64(1), reply.Height)
+	blkID, err := vm.state.GetBlockIDByData([]byte{2})
+	require.NoError(err)
+	require.Equal(blks[2].ID(), blkID)
+
+	data, err = formatting.Encode(formatting.Hex, []byte{3})
+	require.NoError(err)
+	require.ErrorIs(service.GetBlockByData(nil, &GetBlockByDataArgs{Data: data}, reply), errDataNotFound)
+
+	// the data of blocks accepted before the data index existed is indexed
+	// by the backfill
+	legacyState := NewState(memdb.New(), vm)
+	genesisID, err := vm.state.GetBlockIDAtHeight(0)
+	require.NoError(err)
+	genesisBlk, err := vm.state.GetBlock(genesisID)
+	require.NoError(err)
+	for _, blk := range append([]*Block{genesisBlk}, blks...) {
+		require.NoError(legacyState.PutBlock(blk))
+		require.NoError(legacyState.SetBlockIDAtHeight(blk.Height(), blk.ID()))
+		require.NoError(legacyState.SetLastAccepted(blk.ID()))
+	}
+	_, err = legacyState.GetBlockIDByData([]byte{9})
+	require.ErrorIs(err, database.ErrNotFound)
+	vm.state = legacyState
+	require.NoError(vm.initDataIndex())
+	blkID, err = vm.state.GetBlockIDByData([]byte{9})
+	require.NoError(err)
+	require.Equal(blks[0].ID(), blkID)
+	dataIndexed, err := vm.state.IsDataIndexed()
+	require.NoError(err)
+	require.True(dataIndexed)
+}
+
+// require that accepted blocks can be queried by timestamp, a page at a time
+func TestTimeIndex(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// blocks at 100, 100, 200, 300 and 400 seconds
+	timestamps := []int64{100, 100, 200, 300, 400}
+	blkIDs := make([]ids.ID, len(timestamps))
+	for i, timestamp := range timestamps {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), [][]byte{{byte(i)}}, time.Unix(timestamp, 0))
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+		blkIDs[i] = blk.ID()
+	}
+
+	// the blocks in [100, 300) are returned a page at a time
+	service := &Service{vm: vm}
+	reply := &GetBlocksByTimeRangeReply{}
+	require.NoError(service.GetBlocksByTimeRange(nil, &GetBlocksByTimeRangeArgs{Start: 100, End: 300, Limit: 2}, reply))
+	require.Len(reply.Blocks, 2)
+	require.Equal(blkIDs[0], reply.Blocks[0].ID)
+	require.Equal(blkIDs[1], reply.Blocks[1].ID)
+	require.NotNil(reply.NextCursor)
+	require.Equal(json.Uint64(3), *reply.NextCursor)
+	require.NoError(service.GetBlocksByTimeRange(nil, &GetBlocksByTimeRangeArgs{Start: 100, End: 300, Limit: 2, Cursor: reply.NextCursor}, reply))
+	require.Len(reply.Blocks, 1)
+	require.Equal(blkIDs[2], reply.Blocks[0].ID)
+	require.Nil(reply.NextCursor)
+
+	require.NoError(service.GetBlocksByTimeRange(nil, &GetBlocksByTimeRangeArgs{Start: 150, End: 199}, reply))
+	require.Empty(reply.Blocks)
+	require.ErrorIs(service.GetBlocksByTimeRange(nil, &GetBlocksByTimeRangeArgs{Start: 300, End: 100}, reply), errBadTimeRange)
+	badCursor := json.Uint64(10)
+	require.ErrorIs(service.GetBlocksByTimeRange(nil, &GetBlocksByTimeRangeArgs{Start: 100, End: 300, Cursor: &badCursor}, reply), errBadCursor)
+
+	// the latest block at or before a time was the last accepted one then
+	blkReply := &GetBlockReply{}
+	require.NoError(service.GetBlockAtTime(nil, &GetBlockAtTimeArgs{Time: 250}, blkReply))
+	require.Equal(blkIDs[2], blkReply.ID)
+	require.NoError(service.GetBlockAtTime(nil, &GetBlockAtTimeArgs{Time: 100}, blkReply))
+	require.Equal(blkIDs[1], blkReply.ID)
+	require.NoError(service.GetBlockAtTime(nil, &GetBlockAtTimeArgs{Time: 50}, blkReply))
+	require.Equal(json.Uint64(0), blkReply.Height)
+	require.NoError(service.GetBlockAtTime(nil, &GetBlockAtTimeArgs{Time: 1000}, blkReply))
+	require.Equal(blkIDs[4], blkReply.ID)
+}
+
+// require that accepted blocks are listed a page at a time, in either
+// height order
+func TestGetBlocks(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"maxBlocksPerPage":2}`), &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	for i := 1; i <= 4; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i), [][]byte{{byte(i)}}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+	}
+
+	service := &Service{vm: vm}
+	heights := func(reply *GetBlocksReply) []json.Uint64 {
+		heights := make([]json.Uint64, len(reply.Blocks))
+		for i, blk := range reply.Blocks {
+			heights[i] = blk.Height
+		}
+		return heights
+	}
+	height := func(height uint64) *json.Uint64 {
+		h := json.Uint64(height)
+		return &h
+	}
+
+	// pages are bounded by the configured page size, and resumed from the
+	// cursor
+	args := &GetBlocksArgs{StartHeight: height(1)}
+	reply := &GetBlocksReply{}
+	require.NoError(service.GetBlocks(nil, args, reply))
+	require.Equal([]json.Uint64{1, 2}, heights(reply))
+	require.Equal(height(3), reply.NextCursor)
+	args.Cursor = reply.NextCursor
+	require.NoError(service.GetBlocks(nil, args, reply))
+	require.Equal([]json.Uint64{3, 4}, heights(reply))
+	require.Nil(reply.NextCursor)
+
+	// blocks are listed from the last accepted block down
+	require.NoError(service.GetBlocks(nil, &GetBlocksArgs{Descending: true, Limit: 1}, reply))
+	require.Equal([]json.Uint64{4}, heights(reply))
+	require.Equal(height(3), reply.NextCursor)
+	count := json.Uint64(3)
+	require.NoError(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(2), Count: &count, Descending: true}, reply))
+	require.Equal([]json.Uint64{2, 1}, heights(reply))
+	require.Equal(height(0), reply.NextCursor)
+	require.NoError(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(2), EndHeight: height(2)}, reply))
+	require.Equal([]json.Uint64{2}, heights(reply))
+	require.Nil(reply.NextCursor)
+
+	// heights after the last accepted block are left out
+	require.NoError(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(4), Count: &count}, reply))
+	require.Equal([]json.Uint64{4}, heights(reply))
+	require.NoError(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(5)}, reply))
+	require.Empty(reply.Blocks)
+
+	// the range must be in the requested order, and bounded only once
+	require.ErrorIs(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(2), EndHeight: height(1)}, reply), errBadBlockRange)
+	require.ErrorIs(service.GetBlocks(nil, &GetBlocksArgs{EndHeight: height(1), Count: &count}, reply), errBadBlockRange)
+	require.ErrorIs(service.GetBlocks(nil, &GetBlocksArgs{StartHeight: height(2), Cursor: height(1)}, reply), errBadCursor)
+}
+
+// require that decided blocks are pushed to the subscribers of block events,
+// after the accepted blocks they asked to resume from
+func TestBlockEvents(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	accept := func(height uint64, data byte) *Block {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := vm.NewBlock(lastAccepted, height, [][]byte{{data}}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+		return blk
+	}
+	accept(1, 1)
+
+	handlers, err := vm.CreateHandlers(ctx)
+	require.NoError(err)
+	server := httptest.NewServer(handlers[EventsEndpoint].Handler)
+	defer server.Close()
+	subscribe := func(query string) *websocket.Conn {
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?"+query, nil)
+		require.NoError(err)
+		return conn
+	}
+	next := func(conn *websocket.Conn) *BlockEvent {
+		require.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+		event := &BlockEvent{}
+		require.NoError(conn.ReadJSON(event))
+		return event
+	}
+
+	// the accepted blocks are replayed from the requested height, then the
+	// decided blocks are pushed as they are decided
+	all := subscribe("fromHeight=0&rejected=true")
+	defer all.Close()
+	accepted := subscribe("fromHeight=1")
+	defer accepted.Close()
+	for height := json.Uint64(0); height <= 1; height++ {
+		event := next(all)
+		require.Equal(choices.Accepted, event.Status)
+		require.Equal(height, event.Block.Height)
+	}
+	require.Equal(json.Uint64(1), next(accepted).Block.Height)
+
+	lastAccepted, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	rejectedBlk, err := vm.NewBlock(lastAccepted, 2, [][]byte{{3}}, time.Now())
+	require.NoError(err)
+	require.NoError(rejectedBlk.Verify(ctx))
+	acceptedBlk := accept(2, 2)
+	require.NoError(rejectedBlk.Reject(ctx))
+	accept(3, 4)
+
+	event := next(all)
+	require.Equal(choices.Accepted, event.Status)
+	require.Equal(acceptedBlk.ID(), event.Block.ID)
+	event = next(all)
+	require.Equal(choices.Rejected, event.Status)
+	require.Equal(rejectedBlk.ID(), event.Block.ID)
+	require.Equal(json.Uint64(3), next(all).Block.Height)
+
+	// rejected blocks are only pushed if asked for
+	require.Equal(acceptedBlk.ID(), next(accepted).Block.ID)
+	event = next(accepted)
+	require.Equal(choices.Accepted, event.Status)
+	require.Equal(json.Uint64(3), event.Block.Height)
+
+	// the subscription is refused if the query is malformed
+	_, resp, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http")+"?fromHeight=a", nil)
+	require.ErrorIs(err, websocket.ErrBadHandshake)
+	require.Equal(http.StatusBadRequest, resp.StatusCode)
+}
+
+// require that proposals are followed from the mempool to an accepted block,
+// and that the reason they were dropped is reported
+func TestProposalStatus(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":1,"mempoolEvictionPolicy":"drop-oldest"}`), &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	service := &Service{vm: vm}
+	propose := func(data []byte) ids.ID {
+		encoded, err := formatting.Encode(formatting.Hex, data)
+		require.NoError(err)
+		reply := &ProposeBlockReply{}
+		require.NoError(service.ProposeBlock(nil, &ProposeBlockArgs{Data: encoded}, reply))
+		require.True(reply.Success)
+		require.Equal(ProposalID(data), reply.ProposalID)
+		return reply.ProposalID
+	}
+	status := func(proposalID ids.ID) *GetProposalStatusReply {
+		reply := &GetProposalStatusReply{}
+		require.NoError(service.GetProposalStatus(nil, &GetProposalStatusArgs{ProposalID: proposalID}, reply))
+		return reply
+	}
+
+	// proposals are pending in the mempool until they're evicted
+	evictedID := propose([]byte{1})
+	require.Equal(ProposalPending, status(evictedID).Status)
+	builtID := propose([]byte{2})
+	reply := status(evictedID)
+	require.Equal(ProposalDropped, reply.Status)
+	require.Equal("evicted from the full mempool", reply.Reason)
+
+	// proposals put into a block are processing until the block is decided
+	builtBlk, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	require.Equal(&GetProposalStatusReply{
+		Status:  ProposalProcessing,
+		BlockID: builtBlk.ID(),
+		Height:  1,
+	}, status(builtID))
+
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	siblingBlk, err := vm.NewBlock(genesisID, 1, [][]byte{{3}}, time.Now())
+	require.NoError(err)
+	require.NoError(siblingBlk.Verify(ctx))
+
+	// data held by several processing blocks is processing until all of
+	// them are decided
+	duplicateBlk, err := vm.NewBlock(genesisID, 1, [][]byte{{2}}, time.Now().Add(time.Second))
+	require.NoError(err)
+	require.NoError(duplicateBlk.Verify(ctx))
+	require.NoError(duplicateBlk.Reject(ctx))
+	require.Equal(ProposalProcessing, status(builtID).Status)
+
+	require.NoError(siblingBlk.Accept(ctx))
+	require.NoError(builtBlk.Reject(ctx))
+	require.Empty(vm.verifiedData)
+	require.Equal(&GetProposalStatusReply{
+		Status: ProposalDropped,
+		Reason: fmt.Sprintf("block %s was rejected", builtBlk.ID()),
+	}, status(builtID))
+	require.Equal(&GetProposalStatusReply{
+		Status:  ProposalAccepted,
+		BlockID: siblingBlk.ID(),
+		Height:  1,
+	}, status(ProposalID([]byte{3})))
+
+	// proposing dropped data again makes it pending again
+	propose([]byte{2})
+	require.Equal(ProposalPending, status(builtID).Status)
+
+	require.Equal(ProposalUnknown, status(ids.GenerateTestID()).Status)
+}
+
+// require that each proposal of a batch gets its own result, and that the
+// queued data is gossiped in a single message
+func TestProposeBatch(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	var gossiped [][]byte
+	appSender := &common.SenderTest{
+		SendAppGossipF: func(_ context.Context, msgBytes []byte) error {
+			gossiped = append(gossiped, msgBytes)
+			return nil
+		},
+	}
+	vm, snowCtx, msgChan, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":3,"maxDataLen":4,"maxBatchSize":7}`), appSender)
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	service := &Service{vm: vm}
+	encode := func(data []byte) string {
+		encoded, err := formatting.Encode(formatting.Hex, data)
+		require.NoError(err)
+		return encoded
+	}
+
+	args := &ProposeBatchArgs{Data: []string{
+		encode([]byte{1}),
+		"0xzz",
+		encode([]byte{1}),
+		encode([]byte{1, 2, 3, 4, 5}),
+		encode([]byte{2}),
+		encode([]byte{3}),
+		encode([]byte{4}),
+	}}
+	reply := &ProposeBatchReply{}
+	snowCtx.Lock.Lock()
+	require.NoError(service.ProposeBatch(nil, args, reply))
+	snowCtx.Lock.Unlock()
+	require.Equal([]ProposeBatchResult{
+		{ProposalID: ProposalID([]byte{1}), Result: ProposalQueued},
+		{Result: ProposalInvalid, Error: errBadData.Error()},
+		{ProposalID: ProposalID([]byte{1}), Result: ProposalDuplicate},
+		{ProposalID: ProposalID([]byte{1, 2, 3, 4, 5}), Result: ProposalInvalid, Error: verifyDataLen([]byte{1, 2, 3, 4, 5}, 4).Error()},
+		{ProposalID: ProposalID([]byte{2}), Result: ProposalQueued},
+		{ProposalID: ProposalID([]byte{3}), Result: ProposalQueued},
+		{ProposalID: ProposalID([]byte{4}), Result: ProposalMempoolFull},
+	}, reply.Results)
+	require.Equal([][]byte{{1}, {2}, {3}}, vm.mempool.Contents())
+
+	// the engine is notified and the queued data gossiped once
+	require.Equal(common.PendingTxs, <-msgChan)
+	require.Len(gossiped, 1)
+	msg, err := ParseMessage(gossiped[0])
+	require.NoError(err)
+	require.Equal(&DataGossip{Data: [][]byte{{1}, {2}, {3}}}, msg)
+
+	// batches are bounded by the configured size
+	args.Data = append(args.Data, encode([]byte{5}))
+	require.ErrorIs(service.ProposeBatch(nil, args, reply), errBatchTooLarge)
+
+	// accepted data is reported as such rather than queued again
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+	snowCtx.Lock.Lock()
+	require.NoError(service.ProposeBatch(nil, &ProposeBatchArgs{Data: []string{encode([]byte{1})}}, reply))
+	snowCtx.Lock.Unlock()
+	require.Equal([]ProposeBatchResult{
+		{ProposalID: ProposalID([]byte{1}), Result: ProposalAlreadyAccepted},
+	}, reply.Results)
+	require.Equal([][]byte{{2}, {3}}, vm.mempool.Contents())
+}
+
+// require that the REST API serves blocks and proposals with the status
+// codes of their outcome
+func TestREST(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":2}`), &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}}, time.Now())
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	handlers, err := vm.CreateHandlers(ctx)
+	require.NoError(err)
+	// serves [method] [url] with the handler of [endpoint], and decodes the
+	// reply into [reply]
+	serve := func(endpoint, method, url, body string, reply interface{}) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		request := httptest.NewRequest(method, "/ext/bc/chain"+url, strings.NewReader(body))
+		handlers[endpoint].Handler.ServeHTTP(recorder, request)
+		require.Equal("application/json", recorder.Header().Get("Content-Type"))
+		if reply != nil {
+			require.NoError(stdjson.Unmarshal(recorder.Body.Bytes(), reply))
+		}
+		return recorder
+	}
+
+	// blocks are served by ID, by height, or as the last accepted block
+	reply := &GetBlockReply{}
+	recorder := serve(BlockEndpoint, http.MethodGet, "/blocks/latest", "", reply)
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal("no-cache", recorder.Header().Get("Cache-Control"))
+	require.Equal(blk.ID(), reply.ID)
+	reply = &GetBlockReply{}
+	recorder = serve(BlockEndpoint, http.MethodGet, "/blocks/"+genesisID.String(), "", reply)
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal(immutableCacheControl, recorder.Header().Get("Cache-Control"))
+	require.Equal(genesisID, reply.ID)
+	reply = &GetBlockReply{}
+	recorder = serve(BlockAtHeightEndpoint, http.MethodGet, "/blocks/height/1", "", reply)
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal(blk.ID(), reply.ID)
+
+	require.Equal(http.StatusNotFound, serve(BlockEndpoint, http.MethodGet, "/blocks/"+ids.GenerateTestID().String(), "", nil).Code)
+	require.Equal(http.StatusNotFound, serve(BlockAtHeightEndpoint, http.MethodGet, "/blocks/height/2", "", nil).Code)
+	require.Equal(http.StatusBadRequest, serve(BlockEndpoint, http.MethodGet, "/blocks/abc", "", nil).Code)
+	require.Equal(http.StatusBadRequest, serve(BlockAtHeightEndpoint, http.MethodGet, "/blocks/height/abc", "", nil).Code)
+	recorder = serve(BlockEndpoint, http.MethodPost, "/blocks/latest", "", nil)
+	require.Equal(http.StatusMethodNotAllowed, recorder.Code)
+	require.Equal(http.MethodGet, recorder.Header().Get("Allow"))
+
+	// proposals are queued, then followed by their ID
+	propose := func(data []byte) *httptest.ResponseRecorder {
+		encoded, err := formatting.Encode(formatting.Hex, data)
+		require.NoError(err)
+		return serve(ProposalsEndpoint, http.MethodPost, "/proposals", fmt.Sprintf(`{"data":%q}`, encoded), nil)
+	}
+	recorder = propose([]byte{2})
+	require.Equal(http.StatusAccepted, recorder.Code)
+	proposalID := ProposalID([]byte{2})
+	require.Equal("/ext/bc/chain/proposals/"+proposalID.String(), recorder.Header().Get("Location"))
+	statusReply := &GetProposalStatusReply{}
+	recorder = serve(ProposalEndpoint, http.MethodGet, "/proposals/"+proposalID.String(), "", statusReply)
+	require.Equal(http.StatusOK, recorder.Code)
+	require.Equal(ProposalPending, statusReply.Status)
+
+	require.Equal(http.StatusConflict, propose([]byte{2}).Code)
+	require.Equal(http.StatusConflict, propose([]byte{1}).Code)
+	require.Equal(http.StatusBadRequest, propose(nil).Code)
+	require.Equal(http.StatusBadRequest, serve(ProposalsEndpoint, http.MethodPost, "/proposals", `{"data":"0xzz"}`, nil).Code)
+	require.Equal(http.StatusBadRequest, serve(ProposalsEndpoint, http.MethodPost, "/proposals", `data`, nil).Code)
+	require.Equal(http.StatusAccepted, propose([]byte{3}).Code)
+	require.Equal(http.StatusServiceUnavailable, propose([]byte{4}).Code)
+}
+
+// require that the admin API is only served if enabled, and controls the
+// mempool, block building and logs
+func TestAdminService(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	handlers, err := vm.CreateHandlers(ctx)
+	require.NoError(err)
+	require.NotContains(handlers, AdminEndpoint)
+	require.NoError(vm.Shutdown(ctx))
+
+	vm, _, msgChan, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"adminAPIEnabled":true,"mempoolSize":8}`), &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	handlers, err = vm.CreateHandlers(ctx)
+	require.NoError(err)
+	require.Contains(handlers, AdminEndpoint)
+	service := &AdminService{vm: vm}
+
+	require.NoError(vm.proposeBlock([]byte{1}))
+	require.NoError(vm.proposeBlock([]byte{2}))
+	mempoolReply := &GetMempoolReply{}
+	require.NoError(service.GetMempool(nil, nil, mempoolReply))
+	encoded := make([]string, 2)
+	for i, data := range [][]byte{{1}, {2}} {
+		encoded[i], err = formatting.Encode(formatting.Hex, data)
+		require.NoError(err)
+	}
+	require.Equal(&GetMempoolReply{Data: encoded, MaxSize: 8}, mempoolReply)
+
+	// no block is built while building is paused
+	<-msgChan
+	require.NoError(service.PauseBuilding(nil, nil, nil))
+	_, err = vm.BuildBlock(ctx)
+	require.ErrorIs(err, errBuildingPaused)
+	require.NoError(service.GetMempool(nil, nil, mempoolReply))
+	require.True(mempoolReply.BuildingPaused)
+	require.NoError(service.ResumeBuilding(nil, nil, nil))
+	require.Equal(common.PendingTxs, <-msgChan)
+
+	// cleared data is reported as dropped
+	clearReply := &ClearMempoolReply{}
+	require.NoError(service.ClearMempool(nil, nil, clearReply))
+	require.Equal(json.Uint32(2), clearReply.Cleared)
+	require.Zero(vm.mempool.Len())
+	status, err := vm.getProposalStatus(ProposalID([]byte{1}))
+	require.NoError(err)
+	require.Equal(ProposalDropped, status.status)
+	_, err = vm.BuildBlock(ctx)
+	require.ErrorIs(err, errNoPendingBlocks)
+
+	// the level applies to the chain's logger
+	vm.snowCtx.Log = logging.NewLogger("", logging.NewWrappedCore(logging.Info, logging.Discard, logging.Plain.ConsoleEncoder()))
+	defer func() { require.NoError(setLogLevel(vm.snowCtx.Log, DefaultLogLevel)) }()
+	require.False(vm.snowCtx.Log.Enabled(logging.Debug))
+	require.NoError(service.SetLogLevel(nil, &SetLogLevelArgs{Level: "debug"}, nil))
+	require.Equal("debug", vm.config.LogLevel)
+	require.True(vm.snowCtx.Log.Enabled(logging.Debug))
+	require.ErrorIs(service.SetLogLevel(nil, &SetLogLevelArgs{Level: "loud"}, nil), logging.ErrUnknownLevel)
+	require.Equal("debug", vm.config.LogLevel)
+	require.True(vm.snowCtx.Log.Enabled(logging.Debug))
+	require.NoError(service.SetLogLevel(nil, &SetLogLevelArgs{Level: "warn"}, nil))
+	require.False(vm.snowCtx.Log.Enabled(logging.Info))
+}
+
+// require that the metrics count the blocks, the dropped proposals, the block
+// cache lookups and the API calls
+func TestMetrics(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, snowCtx, _, err := newTestVMWithConfig(genesisUpgrades, []byte(`{"mempoolSize":1}`), &common.SenderTest{})
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// the metrics are served by the node
+	families, err := snowCtx.Metrics.Gather()
+	require.NoError(err)
+	names := set.Set[string]{}
+	for _, family := range families {
+		names.Add(family.GetName())
+	}
+	require.True(names.Contains("blocks_accepted"))
+	require.True(names.Contains("mempool_size"))
+
+	require.NoError(vm.proposeBlock([]byte{1}))
+	require.ErrorIs(vm.proposeBlock([]byte{2}), errMempoolFull)
+	require.Equal(1., testutil.ToFloat64(vm.metrics.proposalsDropped.WithLabelValues(dropCauseMempoolFull)))
+
+	built, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	require.Equal(1., testutil.ToFloat64(vm.metrics.blocksBuilt))
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	sibling, err := vm.NewBlock(genesisID, 1, [][]byte{{3}}, time.Now())
+	require.NoError(err)
+	// built blocks are verified by BuildBlock
+	require.NoError(sibling.Verify(ctx))
+	require.Equal(2., testutil.ToFloat64(vm.metrics.blocksVerified))
+	invalid, err := vm.NewBlock(genesisID, 2, [][]byte{{4}}, time.Now())
+	require.NoError(err)
+	require.Error(invalid.Verify(ctx))
+	require.Equal(1., testutil.ToFloat64(vm.metrics.verifyFailures))
+
+	// the genesis block was accepted at initialization
+	require.NoError(built.Accept(ctx))
+	require.NoError(sibling.Reject(ctx))
+	require.Equal(2., testutil.ToFloat64(vm.metrics.blocksAccepted))
+	require.Equal(1., testutil.ToFloat64(vm.metrics.blocksRejected))
+	require.Equal(1., testutil.ToFloat64(vm.metrics.proposalsDropped.WithLabelValues(dropCauseRejected)))
+
+	// blocks read from the database are cached
+	hits := testutil.ToFloat64(vm.metrics.blockCacheHits)
+	misses := testutil.ToFloat64(vm.metrics.blockCacheMisses)
+	_, err = vm.state.GetBlock(ids.GenerateTestID())
+	require.ErrorIs(err, database.ErrNotFound)
+	require.Equal(misses+1, testutil.ToFloat64(vm.metrics.blockCacheMisses))
+	_, err = vm.state.GetBlock(built.ID())
+	require.NoError(err)
+	require.Equal(hits+1, testutil.ToFloat64(vm.metrics.blockCacheHits))
+
+	// JSON-RPC and REST calls are counted by method, along with their errors
+	handlers, err := vm.CreateHandlers(ctx)
+	require.NoError(err)
+	serve := func(endpoint, method, url, body string) {
+		request := httptest.NewRequest(method, "/ext/bc/chain"+url, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		handlers[endpoint].Handler.ServeHTTP(httptest.NewRecorder(), request)
+	}
+	serve("", http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"timestampvm.getBlock","params":{}}`)
+	serve("", http.MethodPost, "", `{"jsonrpc":"2.0","id":1,"method":"timestampvm.getBlockByHeight","params":{"height":"9"}}`)
+	serve(BlockEndpoint, http.MethodGet, "/blocks/latest", "")
+	serve(BlockEndpoint, http.MethodGet, "/blocks/abc", "")
+	require.Equal(1., testutil.ToFloat64(vm.metrics.apiCalls.WithLabelValues("timestampvm.getblock")))
+	require.Zero(testutil.ToFloat64(vm.metrics.apiErrors.WithLabelValues("timestampvm.getblock")))
+	require.Equal(1., testutil.ToFloat64(vm.metrics.apiErrors.WithLabelValues("timestampvm.getblockbyheight")))
+	restMethod := http.MethodGet + " " + BlockEndpoint
+	require.Equal(2., testutil.ToFloat64(vm.metrics.apiCalls.WithLabelValues(restMethod)))
+	require.Equal(1., testutil.ToFloat64(vm.metrics.apiErrors.WithLabelValues(restMethod)))
+}
+
+// require that blocks within the same second are ordered by their
+// milliseconds
+func TestMillisecondTimestamps(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+
+	second := time.Now().Truncate(time.Second)
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}}, second.Add(500*time.Millisecond))
+	require.NoError(err)
+	require.Equal(uint16(500), blk.Ms)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	// a child earlier within the same second is invalid
+	earlyBlk, err := vm.NewBlock(blk.ID(), 2, [][]byte{{2}}, second.Add(499*time.Millisecond))
+	require.NoError(err)
+	require.ErrorIs(earlyBlk.Verify(ctx), errTimestampTooEarly)
+	lateBlk, err := vm.NewBlock(blk.ID(), 2, [][]byte{{2}}, second.Add(501*time.Millisecond))
+	require.NoError(err)
+	require.NoError(lateBlk.Verify(ctx))
+
+	// the milliseconds are within a second
+	badBlk := &Block{
+		PrntID:  blk.ID(),
+		Hght:    2,
+		Tmstmp:  second.Unix(),
+		Ms:      1000,
+		Pylds:   [][]byte{{2}},
+		Sbmsns:  []Submission{{}},
+		version: BlockVersion5,
+	}
+	_, err = vm.initBlock(badBlk)
+	require.NoError(err)
+	require.ErrorIs(badBlk.Verify(ctx), errBadMilliseconds)
+
+	// the API returns the timestamp in milliseconds
+	service := &Service{vm: vm}
+	reply := &GetBlockReply{}
+	require.NoError(service.GetBlock(nil, &GetBlockArgs{}, reply))
+	require.Equal(json.Uint64(second.Unix()), reply.Timestamp)
+	require.Equal(json.Uint64(second.Add(500*time.Millisecond).UnixMilli()), reply.TimestampMs)
+}
+
+// require that signed data records its signer, and that each nonce of a
+// signer is used at most once
+func TestSignedProposals(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	require.NoError(vm.SetPreference(ctx, genesisID))
+
+	key, err := (&secp256k1.Factory{}).NewPrivateKey()
+	require.NoError(err)
+	sign := func(nonce uint64, data []byte) *Submission {
+		sub, err := SignSubmission(key, vm.snowCtx.ChainID, nonce, data)
+		require.NoError(err)
+		return sub
+	}
+	// returns a child of [parent] holding [data] signed by [sub]
+	newSignedBlock := func(parent *Block, data []byte, sub *Submission) *Block {
+		blk, err := vm.newBlock(parent.ID(), parent.Height()+1, [][]byte{data}, []Submission{*sub}, time.Now())
+		require.NoError(err)
+		return blk
+	}
+
+	// the signature must be from the signer, for the data and the chain
+	badSub := sign(0, []byte{1})
+	badSub.Signer = ids.GenerateTestShortID()
+	require.ErrorIs(vm.proposeSignedBlock([]byte{1}, badSub), errWrongSigner)
+	require.ErrorIs(sign(0, []byte{1}).Verify(ids.GenerateTestID(), []byte{1}), errWrongSigner)
+
+	// data reusing a nonce is dropped when the block is built
+	require.NoError(vm.proposeSignedBlock([]byte{1}, sign(0, []byte{1})))
+	require.NoError(vm.proposeBlock([]byte{2}))
+	require.NoError(vm.proposeSignedBlock([]byte{3}, sign(0, []byte{3})))
+	snowmanBlock, err := vm.BuildBlock(ctx)
+	require.NoError(err)
+	blk := snowmanBlock.(*Block)
+	require.Equal([][]byte{{1}, {2}}, blk.Entries())
+	require.Equal([]Submission{*sign(0, []byte{1}), {}}, blk.Submissions())
+	require.Zero(vm.mempool.Len())
+	require.NoError(blk.Verify(ctx))
+
+	// the nonces of processing blocks are used
+	require.ErrorIs(newSignedBlock(blk, []byte{4}, sign(0, []byte{4})).Verify(ctx), errNonceUsed)
+
+	require.NoError(blk.Accept(ctx))
+	require.NoError(vm.SetPreference(ctx, blk.ID()))
+
+	// the nonces of accepted blocks are used
+	require.ErrorIs(vm.proposeSignedBlock([]byte{4}, sign(0, []byte{4})), errNonceUsed)
+	require.ErrorIs(newSignedBlock(blk, []byte{4}, sign(0, []byte{4})).Verify(ctx), errNonceUsed)
+	used, err := vm.state.IsNonceUsed(key.Address(), 0)
+	require.NoError(err)
+	require.True(used)
+
+	// a block can't use a nonce twice
+	dupBlk, err := vm.newBlock(blk.ID(), blk.Height()+1, [][]byte{{4}, {5}}, []Submission{*sign(1, []byte{4}), *sign(1, []byte{5})}, time.Now())
+	require.NoError(err)
+	require.ErrorIs(dupBlk.Verify(ctx), errDuplicateNonce)
+
+	// signed data is proposed and its signer returned through the API
+	service := &Service{vm: vm}
+	data, err := formatting.Encode(formatting.Hex, []byte{6})
+	require.NoError(err)
+	sig, err := formatting.Encode(formatting.Hex, sign(1, []byte{6}).Sig[:])
+	require.NoError(err)
+	proposeReply := &ProposeBlockReply{}
+	require.NoError(service.ProposeBlock(nil, &ProposeBlockArgs{Data: data, Signature: sig, Nonce: 1}, proposeReply))
+	require.True(proposeReply.Success)
+	snowmanBlock, err = vm.BuildBlock(ctx)
+	require.NoError(err)
+	require.NoError(snowmanBlock.Verify(ctx))
+	require.NoError(snowmanBlock.Accept(ctx))
+
+	reply := &GetBlockReply{}
+	require.NoError(service.GetBlock(nil, &GetBlockArgs{}, reply))
+	require.Equal(snowmanBlock.ID(), reply.ID)
+	require.Equal([]string{key.Address().String()}, reply.Signers)
+	require.NoError(service.GetBlockByHeight(nil, &GetBlockByHeightArgs{Height: 1}, reply))
+	require.Equal([]string{key.Address().String(), ""}, reply.Signers)
+}
+
+// require that accepted data is signed over Warp, and that the signatures of
+// the validators aggregate into a verifiable Warp message
+func TestWarpSignatures(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	sk, err := bls.NewSecretKey()
+	require.NoError(err)
+	vm.snowCtx.WarpSigner = warp.NewSigner(sk, blockchainID)
+
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	timestamp := time.Unix(time.Now().Unix(), int64(123*time.Millisecond))
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}, {2}}, timestamp)
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	// accepting data signs nothing
+	acceptedMsg, err := NewWarpMessage(blockchainID, blk, []byte{2})
+	require.NoError(err)
+	_, ok := vm.warpSignatures.Get(acceptedMsg.ID())
+	require.False(ok)
+
+	// the signature of accepted data is cached, and covers its height and
+	// timestamp, to the millisecond
+	service := &Service{vm: vm}
+	data, err := formatting.Encode(formatting.Hex, []byte{2})
+	require.NoError(err)
+	reply := &GetWarpSignatureReply{}
+	require.NoError(service.GetWarpSignature(nil, &GetWarpSignatureArgs{Height: 1, Data: data}, reply))
+	msgBytes, err := formatting.Decode(formatting.Hex, reply.Message)
+	require.NoError(err)
+	msg, err := warp.ParseUnsignedMessage(msgBytes)
+	require.NoError(err)
+	require.Equal(reply.MessageID, msg.ID())
+	require.Equal(blockchainID, msg.SourceChainID)
+	payload, err := ParseWarpPayload(msg.Payload)
+	require.NoError(err)
+	require.Equal(&WarpPayload{Height: 1, Timestamp: timestamp.Unix(), Data: []byte{2}, TimestampMs: timestamp.UnixMilli()}, payload)
+
+	// the payloads of blocks before milliseconds keep their format
+	legacyBlk := &Block{Hght: 2, Tmstmp: timestamp.Unix(), version: BlockVersion4}
+	legacyMsg, err := NewWarpMessage(blockchainID, legacyBlk, []byte{3})
+	require.NoError(err)
+	require.Equal([]byte{0, CodecVersion}, legacyMsg.Payload[:2])
+	payload, err = ParseWarpPayload(legacyMsg.Payload)
+	require.NoError(err)
+	require.Equal(&WarpPayload{Height: 2, Timestamp: timestamp.Unix(), Data: []byte{3}}, payload)
+	sigBytes, err := formatting.Decode(formatting.Hex, reply.Signature)
+	require.NoError(err)
+	cachedSig, ok := vm.warpSignatures.Get(msg.ID())
+	require.True(ok)
+	require.Equal(cachedSig, sigBytes)
+
+	// data accepted before the node had a signer is signed as well
+	genesisData, err := formatting.Encode(formatting.Hex, make([]byte, DataLen))
+	require.NoError(err)
+	require.NoError(service.GetWarpSignature(nil, &GetWarpSignatureArgs{Height: 0, Data: genesisData}, &GetWarpSignatureReply{}))
+
+	// the signatures of the validators are aggregated
+	otherSK, err := bls.NewSecretKey()
+	require.NoError(err)
+	nodeID, otherNodeID := ids.GenerateTestNodeID(), ids.GenerateTestNodeID()
+	validatorSet := map[ids.NodeID]*validators.GetValidatorOutput{
+		nodeID:      {NodeID: nodeID, PublicKey: bls.PublicFromSecretKey(sk), Weight: 1},
+		otherNodeID: {NodeID: otherNodeID, PublicKey: bls.PublicFromSecretKey(otherSK), Weight: 1},
+	}
+	pChainState := &validators.TestState{
+		GetSubnetIDF: func(context.Context, ids.ID) (ids.ID, error) {
+			return ids.Empty, nil
+		},
+		GetValidatorSetF: func(context.Context, uint64, ids.ID) (map[ids.NodeID]*validators.GetValidatorOutput, error) {
+			return validatorSet, nil
+		},
+	}
+	vdrs, _, err := warp.GetCanonicalValidatorSet(ctx, pChainState, 0, ids.Empty)
+	require.NoError(err)
+	otherSig := bls.SignatureToBytes(bls.Sign(otherSK, msg.Bytes()))
+	signedMsg, err := AggregateWarpSignatures(msg, vdrs, map[ids.NodeID][]byte{
+		nodeID:      sigBytes,
+		otherNodeID: otherSig,
+	})
+	require.NoError(err)
+	require.NoError(signedMsg.Signature.Verify(ctx, &signedMsg.UnsignedMessage, pChainState, 0, 1, 1))
+
+	// signatures are checked before they're aggregated
+	_, err = AggregateWarpSignatures(msg, vdrs, map[ids.NodeID][]byte{nodeID: otherSig})
+	require.ErrorIs(err, errBadWarpSignature)
+	_, err = AggregateWarpSignatures(msg, vdrs, map[ids.NodeID][]byte{ids.GenerateTestNodeID(): sigBytes})
+	require.ErrorIs(err, errUnknownWarpSigner)
+	_, err = AggregateWarpSignatures(msg, vdrs, nil)
+	require.ErrorIs(err, errNoWarpSignatures)
+}
+
+// require that the timestamps of data are sent to other chains, and that
+// failing to find them isn't fatal to the chain
+func TestCrossChainTimestampRequest(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	var responses []*TimestampResponse
+	sender := &common.SenderTest{
+		SendCrossChainAppResponseF: func(_ context.Context, _ ids.ID, _ uint32, responseBytes []byte) {
+			response := &TimestampResponse{}
+			_, err := Codec.Unmarshal(responseBytes, response)
+			require.NoError(err)
+			responses = append(responses, response)
+		},
+	}
+	vm, _, _, err := newTestVMWithConfig(genesisUpgrades, nil, sender)
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	genesisID, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+	timestamp := time.Unix(time.Now().Unix(), int64(456*time.Millisecond))
+	blk, err := vm.NewBlock(genesisID, 1, [][]byte{{1}}, timestamp)
+	require.NoError(err)
+	require.NoError(blk.Verify(ctx))
+	require.NoError(blk.Accept(ctx))
+
+	request := func(data []byte) {
+		requestBytes, err := BuildCrossChainRequest(&TimestampRequest{Data: data})
+		require.NoError(err)
+		require.NoError(vm.CrossChainAppRequest(ctx, ids.GenerateTestID(), 1, time.Time{}, requestBytes))
+	}
+	request([]byte{1})
+	request([]byte{2})
+	require.Equal([]*TimestampResponse{
+		{Accepted: true, BlkID: blk.ID(), Height: 1, Timestamp: timestamp.Unix(), TimestampMs: timestamp.UnixMilli()},
+		{},
+	}, responses)
+
+	// data indexed at a block that can't be read is reported as not accepted
+	missingBlk, err := vm.NewBlock(blk.ID(), 2, [][]byte{{3}}, time.Now())
+	require.NoError(err)
+	require.NoError(vm.state.IndexData(missingBlk))
+	responses = nil
+	request([]byte{3})
+	require.Equal([]*TimestampResponse{{}}, responses)
+}
+
+func TestSetState(t *testing.T) {
+	// Initialize the vm
+	require := require.New(t)
+	ctx := context.TODO()
+	// Initialize the vm
+	vm, _, _, err := newTestVM()
+	require.NoError(err)
+	// state syncing
+	require.NoError(vm.SetState(ctx, snow.StateSyncing))
+	require.False(vm.bootstrapped.Get())
+	// bootstrapping
+	require.NoError(vm.SetState(ctx, snow.Bootstrapping))
+	require.False(vm.bootstrapped.Get())
+	// bootstrapped
+	require.NoError(vm.SetState(ctx, snow.NormalOp))
+	require.True(vm.bootstrapped.Get())
+	// unknown
+	unknownState := snow.State(99)
+	require.ErrorIs(vm.SetState(ctx, unknownState), snow.ErrUnknownState)
+}
+
+func TestGossip(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	// Initialize the vm with a sender recording the gossip it sends
+	var gossiped [][]byte
+	appSender := &common.SenderTest{
+		SendAppGossipF: func(_ context.Context, msgBytes []byte) error {
+			gossiped = append(gossiped, msgBytes)
+			return nil
+		},
+	}
+	vm, snowCtx, _, err := newTestVMWithConfig(genesisUpgrades, nil, appSender)
+	require.NoError(err)
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+
+	// proposed data is gossiped to the network
+	snowCtx.Lock.Lock()
+	require.NoError(vm.proposeBlock([]byte{1}))
+	snowCtx.Lock.Unlock()
+	require.Len(gossiped, 1)
+	msg, err := ParseMessage(gossiped[0])
+	require.NoError(err)
+	require.Equal(&DataGossip{Data: [][]byte{{1}}}, msg)
+
+	// gossip received from a peer is added to the mempool and gossiped again
+	nodeID := ids.GenerateTestNodeID()
+	msgBytes, err := BuildMessage(&DataGossip{Data: [][]byte{{1}, {2}}})
+	require.NoError(err)
+	require.NoError(vm.AppGossip(ctx, nodeID, msgBytes))
+	require.Equal([][]byte{{1}, {2}}, vm.mempool.Contents())
+	require.Len(gossiped, 2)
+	msg, err = ParseMessage(gossiped[1])
+	require.NoError(err)
+	require.Equal(&DataGossip{Data: [][]byte{{2}}}, msg)
+
+	// data that was already seen is neither added nor gossiped again
+	require.NoError(vm.AppGossip(ctx, nodeID, msgBytes))
+	require.Equal(2, vm.mempool.Len())
+	require.Len(gossiped, 2)
+
+	// signed data is gossiped along with its submission
+	key, err := (&secp256k1.Factory{}).NewPrivateKey()
+	require.NoError(err)
+	sub, err := SignSubmission(key, vm.snowCtx.ChainID, 0, []byte{3})
+	require.NoError(err)
+	msgBytes, err = BuildMessage(&SignedDataGossip{Data: [][]byte{{3}, {4}}, Sbmsns: []Submission{*sub, {}}})
+	require.NoError(err)
+	require.NoError(vm.AppGossip(ctx, nodeID, msgBytes))
+	require.Equal(sub, vm.mempool.Submission([]byte{3}))
+	require.Nil(vm.mempool.Submission([]byte{4}))
+	require.Len(gossiped, 4)
+	msg, err = ParseMessage(gossiped[2])
+	require.NoError(err)
+	require.Equal(&SignedDataGossip{Data: [][]byte{{3}}, Sbmsns: []Submission{*sub}}, msg)
+	msg, err = ParseMessage(gossiped[3])
+	require.NoError(err)
+	require.Equal(&DataGossip{Data: [][]byte{{4}}}, msg)
+
+	// invalid messages are dropped
+	require.NoError(vm.AppGossip(ctx, nodeID, []byte{1, 2, 3}))
+}
+
+func newTestVM() (*VM, *snow.Context, chan common.Message, error) {
+	return newTestVMWithConfig(genesisUpgrades, nil, &common.SenderTest{})
+}
+
+func newTestVMWithConfig(upgradeBytes, configBytes []byte, appSender common.AppSender) (*VM, *snow.Context, chan common.Message, error) {
 	dbManager := manager.NewMemDB(&version.Semantic{
 		Major: 1,
 		Minor: 0,
@@ -174,6 +1888,6 @@ func newTestVM() (*VM, *snow.Context, chan common.Message, error) {
 	vm := &VM{}
 	snowCtx := snow.DefaultContextTest()
 	snowCtx.ChainID = blockchainID
-	err := vm.Initialize(context.TODO(), snowCtx, dbManager, []byte{0, 0, 0, 0, 0}, nil, nil, msgChan, nil, nil)
+	err := vm.Initialize(context.TODO(), snowCtx, dbManager, []byte{0, 0, 0, 0, 0}, upgradeBytes, configBytes, msgChan, nil, appSender)
 	return vm, snowCtx, msgChan, err
 }
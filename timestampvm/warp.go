@@ -0,0 +1,140 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package timestampvm
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/utils/crypto/bls"
+	"github.com/ava-labs/avalanchego/utils/set"
+	"github.com/ava-labs/avalanchego/vms/platformvm/warp"
+)
+
+// maximum number of recent Warp signatures to remember
+const warpSignatureCacheSize = 1024
+
+var (
+	errNoWarpSigner      = errors.New("node has no Warp signer")
+	errNoWarpSignatures  = errors.New("no signatures to aggregate")
+	errBadWarpSignature  = errors.New("signature isn't from the validator over the message")
+	errUnknownWarpSigner = errors.New("signer isn't in the validator set")
+)
+
+// WarpPayload is the payload of the Warp message signed by the nodes for each
+// piece of accepted data. The source chain of the message is the chain the
+// data was accepted on, and its destination is empty, so the message can be
+// verified by any chain.
+// The payload of data accepted in a [BlockVersion5] block is marshalled with
+// that codec version, so it holds the timestamp in milliseconds as well.
+type WarpPayload struct {
+	// Height of the block the data was accepted in
+	Height uint64 `serialize:"true" json:"height"`
+	// Timestamp, in Unix seconds, of the block the data was accepted in
+	Timestamp int64 `serialize:"true" json:"timestamp"`
+	// The timestamped data
+	Data []byte `serialize:"true" json:"data"`
+	// Timestamp, in Unix milliseconds, of the block the data was accepted in.
+	// Zero for blocks before [BlockVersion5].
+	TimestampMs int64 `v5:"true" json:"timestampMs"`
+}
+
+// NewWarpMessage returns the Warp message stating that [data] was accepted on
+// the chain [chainID] in [blk]
+func NewWarpMessage(chainID ids.ID, blk *Block, data []byte) (*warp.UnsignedMessage, error) {
+	payload := &WarpPayload{
+		Height:    blk.Height(),
+		Timestamp: blk.Tmstmp,
+		Data:      data,
+	}
+	if blk.version >= BlockVersion5 {
+		payload.TimestampMs = blk.Timestamp().UnixMilli()
+	}
+	payloadBytes, err := Codec.Marshal(payloadCodecVersion(blk), payload)
+	if err != nil {
+		return nil, err
+	}
+	return warp.NewUnsignedMessage(chainID, ids.Empty, payloadBytes)
+}
+
+// ParseWarpPayload unmarshals the payload of a Warp message of this VM
+func ParseWarpPayload(payloadBytes []byte) (*WarpPayload, error) {
+	payload := &WarpPayload{}
+	if _, err := Codec.Unmarshal(payloadBytes, payload); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// getWarpSignature returns the signature of this node over [msg], the Warp
+// message of accepted data. Messages are signed when their signature is first
+// requested, and the recent signatures are cached, as the validators are
+// usually asked for the same messages at about the same time.
+func (vm *VM) getWarpSignature(msg *warp.UnsignedMessage) ([]byte, error) {
+	if sig, ok := vm.warpSignatures.Get(msg.ID()); ok {
+		return sig, nil
+	}
+	if vm.snowCtx.WarpSigner == nil {
+		return nil, errNoWarpSigner
+	}
+	sig, err := vm.snowCtx.WarpSigner.Sign(msg)
+	if err != nil {
+		return nil, err
+	}
+	vm.warpSignatures.Put(msg.ID(), sig)
+	return sig, nil
+}
+
+// AggregateWarpSignatures returns [msg] signed by the aggregate of [sigs],
+// the signatures of validators by their node IDs. [vdrs] is the canonical
+// validator set of the subnet, as returned by [warp.GetCanonicalValidatorSet],
+// so the signers are recorded by their index in it.
+// Each signature is checked before it's aggregated. Whether the signers hold
+// enough weight is left to the verifier of the message.
+func AggregateWarpSignatures(msg *warp.UnsignedMessage, vdrs []*warp.Validator, sigs map[ids.NodeID][]byte) (*warp.Message, error) {
+	// Validators may be registered under several node IDs, yet sign once
+	indices := make(map[ids.NodeID]int)
+	for i, vdr := range vdrs {
+		for _, nodeID := range vdr.NodeIDs {
+			indices[nodeID] = i
+		}
+	}
+
+	var (
+		signers    = set.NewBits()
+		signatures = make([]*bls.Signature, 0, len(sigs))
+	)
+	for nodeID, sigBytes := range sigs {
+		index, ok := indices[nodeID]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownWarpSigner, nodeID)
+		}
+		if signers.Contains(index) {
+			continue
+		}
+		sig, err := bls.SignatureFromBytes(sigBytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse signature of %s: %w", nodeID, err)
+		}
+		if !bls.Verify(vdrs[index].PublicKey, sig, msg.Bytes()) {
+			return nil, fmt.Errorf("%w: %s", errBadWarpSignature, nodeID)
+		}
+		signers.Add(index)
+		signatures = append(signatures, sig)
+	}
+	if len(signatures) == 0 {
+		return nil, errNoWarpSignatures
+	}
+
+	aggregate, err := bls.AggregateSignatures(signatures)
+	if err != nil {
+		return nil, err
+	}
+	signature := &warp.BitSetSignature{
+		Signers: signers.Bytes(),
+	}
+	copy(signature.Signature[:], bls.SignatureToBytes(aggregate))
+	return warp.NewMessage(msg, signature)
+}
@@ -0,0 +1,124 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package verifier checks timestampvm receipts offline, without running or
+// trusting a node.
+package verifier
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/ava-labs/avalanchego/ids"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+var (
+	errNoBlocks          = errors.New("receipt holds no blocks")
+	errTooManyBlocks     = fmt.Errorf("receipt holds more than %d blocks", timestampvm.MaxReceiptBlocks)
+	errReceiptTooLarge   = fmt.Errorf("receipt is larger than %d bytes", timestampvm.MaxReceiptSize)
+	errDataNotInBlock    = errors.New("data isn't in the first block of the receipt")
+	errWrongData         = errors.New("receipt is for other data")
+	errBrokenChain       = errors.New("block isn't the child of the previous block of the receipt")
+	errWrongCheckpoint   = errors.New("last block of the receipt isn't the checkpoint")
+	errTimestampTooEarly = errors.New("block's timestamp is earlier than its parent's timestamp")
+)
+
+// Result is what a valid receipt shows: the data was accepted in the block
+// [BlockID], at [Height] and [Timestamp]
+type Result struct {
+	BlockID   ids.ID
+	Height    uint64
+	Timestamp time.Time
+}
+
+// ParseReceipt unmarshals [receiptBytes] into a receipt
+func ParseReceipt(receiptBytes []byte) (*timestampvm.Receipt, error) {
+	receipt := &timestampvm.Receipt{}
+	if _, err := timestampvm.ReceiptCodec.Unmarshal(receiptBytes, receipt); err != nil {
+		return nil, err
+	}
+	return receipt, nil
+}
+
+// Verify returns what [receiptBytes] shows about [data], if the receipt is
+// valid and leads to the block [checkpoint].
+// The ID of each block in the receipt is re-derived from its bytes, as nodes
+// do, so only [checkpoint] has to be known to be accepted, for instance by
+// asking several nodes or from a published list of checkpoints.
+func Verify(receiptBytes []byte, data []byte, checkpoint ids.ID) (*Result, error) {
+	receipt, err := ParseReceipt(receiptBytes)
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(receipt.Data, data) {
+		return nil, errWrongData
+	}
+	return VerifyReceipt(receipt, checkpoint)
+}
+
+// VerifyReceipt returns what [receipt] shows about its data, if it's valid
+// and leads to the block [checkpoint]
+func VerifyReceipt(receipt *timestampvm.Receipt, checkpoint ids.ID) (*Result, error) {
+	switch {
+	case len(receipt.Blks) == 0:
+		return nil, errNoBlocks
+	case len(receipt.Blks) > timestampvm.MaxReceiptBlocks:
+		return nil, errTooManyBlocks
+	}
+	size := len(receipt.Data)
+	for _, blkBytes := range receipt.Blks {
+		size += len(blkBytes)
+	}
+	if size > timestampvm.MaxReceiptSize {
+		return nil, errReceiptTooLarge
+	}
+
+	// The data must be in the first block
+	blk, err := timestampvm.UnmarshalBlock(receipt.Blks[0])
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse block 0 of the receipt: %w", err)
+	}
+	if !containsData(blk, receipt.Data) {
+		return nil, errDataNotInBlock
+	}
+	result := &Result{
+		BlockID:   blk.ID(),
+		Height:    blk.Height(),
+		Timestamp: blk.Timestamp(),
+	}
+
+	// Each following block must be the child of the previous one, up to the
+	// checkpoint
+	parent := blk
+	for i, blkBytes := range receipt.Blks[1:] {
+		blk, err := timestampvm.UnmarshalBlock(blkBytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse block %d of the receipt: %w", i+1, err)
+		}
+		if blk.Parent() != parent.ID() || blk.Height() != parent.Height()+1 {
+			return nil, fmt.Errorf("%w: block %d", errBrokenChain, i+1)
+		}
+		if blk.Timestamp().Before(parent.Timestamp()) {
+			return nil, fmt.Errorf("%w: block %d", errTimestampTooEarly, i+1)
+		}
+		parent = blk
+	}
+	if parent.ID() != checkpoint {
+		return nil, fmt.Errorf("%w: expected %s, found %s", errWrongCheckpoint, checkpoint, parent.ID())
+	}
+	return result, nil
+}
+
+// containsData returns true if [data] is one of the pieces of data in [blk]
+func containsData(blk *timestampvm.Block, data []byte) bool {
+	for _, entry := range blk.Entries() {
+		if bytes.Equal(entry, data) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2019-2023, Ava Labs, Inc. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package verifier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/ava-labs/avalanchego/database/manager"
+	"github.com/ava-labs/avalanchego/ids"
+	"github.com/ava-labs/avalanchego/snow"
+	"github.com/ava-labs/avalanchego/snow/engine/common"
+	"github.com/ava-labs/avalanchego/utils/units"
+	"github.com/ava-labs/avalanchego/version"
+	"github.com/stretchr/testify/require"
+
+	"github.com/ava-labs/timestampvm/timestampvm"
+)
+
+func TestVerify(t *testing.T) {
+	require := require.New(t)
+	ctx := context.TODO()
+
+	// Build a chain of 3 blocks after genesis
+	vm := &timestampvm.VM{}
+	dbManager := manager.NewMemDB(&version.Semantic{Major: 1})
+	// activate every upgrade from genesis
+	upgradeBytes := []byte(`[{"name":"multiEntry","timestamp":0},{"name":"variableLengthData","timestamp":0},{"name":"merkleRoot","timestamp":0},{"name":"signedData","timestamp":0},{"name":"millisecondTimestamp","timestamp":0}]`)
+	require.NoError(vm.Initialize(ctx, snow.DefaultContextTest(), dbManager, []byte{1}, upgradeBytes, nil, nil, nil, &common.SenderTest{}))
+	defer func() { require.NoError(vm.Shutdown(ctx)) }()
+	blks := make([][]byte, 0, 3)
+	for i := 0; i < 3; i++ {
+		lastAccepted, err := vm.LastAccepted(ctx)
+		require.NoError(err)
+		blk, err := vm.NewBlock(lastAccepted, uint64(i+1), [][]byte{{byte(i)}, {byte(i), 1}}, time.Now())
+		require.NoError(err)
+		require.NoError(blk.Verify(ctx))
+		require.NoError(blk.Accept(ctx))
+		blks = append(blks, blk.Bytes())
+	}
+	checkpoint, err := vm.LastAccepted(ctx)
+	require.NoError(err)
+
+	receipt := &timestampvm.Receipt{
+		Data: []byte{0, 1},
+		Blks: blks,
+	}
+	receiptBytes, err := timestampvm.ReceiptCodec.Marshal(timestampvm.CodecVersion, receipt)
+	require.NoError(err)
+
+	// the receipt shows the data was accepted in the first block
+	result, err := Verify(receiptBytes, []byte{0, 1}, checkpoint)
+	require.NoError(err)
+	firstBlk, err := timestampvm.UnmarshalBlock(blks[0])
+	require.NoError(err)
+	require.Equal(firstBlk.ID(), result.BlockID)
+	require.Equal(uint64(1), result.Height)
+	require.Equal(firstBlk.Timestamp(), result.Timestamp)
+
+	// the receipt only holds for its data and checkpoint
+	_, err = Verify(receiptBytes, []byte{0, 2}, checkpoint)
+	require.ErrorIs(err, errWrongData)
+	_, err = Verify(receiptBytes, []byte{0, 1}, ids.GenerateTestID())
+	require.ErrorIs(err, errWrongCheckpoint)
+
+	// tampered receipts are invalid
+	_, err = VerifyReceipt(&timestampvm.Receipt{Data: []byte{2}, Blks: blks}, checkpoint)
+	require.ErrorIs(err, errDataNotInBlock)
+	_, err = VerifyReceipt(&timestampvm.Receipt{Data: []byte{0, 1}, Blks: [][]byte{blks[0], blks[2]}}, checkpoint)
+	require.ErrorIs(err, errBrokenChain)
+	_, err = VerifyReceipt(&timestampvm.Receipt{Data: []byte{0, 1}}, checkpoint)
+	require.ErrorIs(err, errNoBlocks)
+	_, err = VerifyReceipt(&timestampvm.Receipt{Data: make([]byte, timestampvm.MaxReceiptSize), Blks: blks}, checkpoint)
+	require.ErrorIs(err, errReceiptTooLarge)
+
+	// receipts larger than the default codec allows can be parsed
+	receipt = &timestampvm.Receipt{
+		Data: []byte{0, 1},
+		Blks: [][]byte{make([]byte, 200*units.KiB), make([]byte, 200*units.KiB)},
+	}
+	receiptBytes, err = timestampvm.ReceiptCodec.Marshal(timestampvm.CodecVersion, receipt)
+	require.NoError(err)
+	parsed, err := ParseReceipt(receiptBytes)
+	require.NoError(err)
+	require.Equal(receipt, parsed)
+}